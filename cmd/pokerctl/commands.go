@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/cleanup"
+)
+
+// maxImportLineBytes bounds a single exported room line.
+const maxImportLineBytes = 16 * 1024 * 1024
+
+func runList(store roomStore, _ string, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	idleAfter := fs.Duration("idle-after", 0, "only rooms idle for at least this long")
+	minMembers := fs.Int("min-members", 0, "only rooms with at least this many members")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	records, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	threshold := time.Now().Add(-*idleAfter)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tMEMBERS\tTICKETS\tUPDATED")
+	for _, r := range records {
+		if *idleAfter > 0 && r.Room.UpdatedAt.After(threshold) {
+			continue
+		}
+		if len(r.Room.Members) < *minMembers {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n",
+			r.ID, r.Room.Name, r.Room.Status, len(r.Room.Members), len(r.Room.TicketQueue),
+			r.Room.UpdatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runInspect(store roomStore, _ string, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pokerctl inspect <roomId>")
+	}
+
+	id := fs.Arg(0)
+	room, found, err := store.Get(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("room %s not found", id)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(domain.RoomRecord{ID: id, Room: room})
+}
+
+// exportRecord is a line of an export, including the fields Room keeps out of
+// its JSON, so private rooms and bans survive export and import.
+type exportRecord struct {
+	domain.RoomRecord
+	serverFields
+}
+
+func runExport(store roomStore, _ string, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "output file (defaults to stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var records []domain.RoomRecord
+	if fs.NArg() == 0 {
+		all, err := store.List()
+		if err != nil {
+			return err
+		}
+		records = all
+	} else {
+		for _, id := range fs.Args() {
+			room, found, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("room %s not found", id)
+			}
+			records = append(records, domain.RoomRecord{ID: id, Room: room})
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(exportRecord{RoomRecord: r, serverFields: serverFieldsOf(r.Room)}); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d room(s)\n", len(records))
+	return nil
+}
+
+func runImport(store roomStore, _ string, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := fs.String("i", "", "input file of JSON lines (defaults to stdin)")
+	overwrite := fs.Bool("overwrite", false, "replace rooms that already exist")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+	imported, skipped, line := 0, 0, 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record exportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		record.restore(&record.Room)
+		if record.ID == "" {
+			return fmt.Errorf("line %d: missing id", line)
+		}
+
+		_, exists, err := store.Get(record.ID)
+		if err != nil {
+			return err
+		}
+		if exists && !*overwrite {
+			skipped++
+			continue
+		}
+		if !*dryRun {
+			if err := store.Put(record.ID, record.Room); err != nil {
+				return fmt.Errorf("room %s: %w", record.ID, err)
+			}
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d room(s), skipped %d existing%s\n", imported, skipped, dryRunSuffix(*dryRun))
+	return nil
+}
+
+func runDelete(store roomStore, _ string, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "confirm deletion")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: pokerctl delete -yes <roomId>...")
+	}
+	if !*yes {
+		return errors.New("refusing to delete without -yes")
+	}
+
+	for _, id := range fs.Args() {
+		found, err := store.Delete(id)
+		if err != nil {
+			return fmt.Errorf("room %s: %w", id, err)
+		}
+		if !found {
+			fmt.Printf("%s: not found\n", id)
+			continue
+		}
+		fmt.Printf("%s: deleted\n", id)
+	}
+	return nil
+}
+
+func runCleanup(store roomStore, backend string, args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	retention := fs.Duration("retention", 30*24*time.Hour, "delete rooms idle longer than this")
+	batchSize := fs.Int("batch-size", 100, "rooms per delete transaction (firestore backends)")
+	archive := fs.String("archive", configs.ArchiveNone, "archive before deleting: none, collection or file")
+	archiveFile := fs.String("archive-file", "archive/rooms.jsonl", "JSON lines file for -archive file")
+	dryRun := fs.Bool("dry-run", false, "report what would be deleted without deleting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *batchSize < 1 || *batchSize > 250 {
+		return errors.New("-batch-size must be between 1 and 250")
+	}
+	switch *archive {
+	case configs.ArchiveNone, configs.ArchiveCollection, configs.ArchiveFile:
+	default:
+		return fmt.Errorf("unknown -archive mode %q", *archive)
+	}
+
+	opts := cleanup.Options{
+		Retention:   *retention,
+		BatchSize:   *batchSize,
+		Archive:     *archive,
+		ArchiveFile: *archiveFile,
+		DryRun:      *dryRun,
+	}
+
+	var (
+		result domain.CleanupResult
+		err    error
+	)
+	if backend == backendLocal {
+		result, err = cleanupLocal(store, opts)
+	} else {
+		result, err = cleanup.Run(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, r := range result.Rooms {
+		fmt.Printf("%s\t%s\t%s\n", r.ID, r.Name, r.UpdatedAt.Format(time.RFC3339))
+	}
+	fmt.Println(result.Message)
+	return nil
+}
+
+// cleanupLocal mirrors cleanup.Run for the local backend, which has no
+// transactions or archive collection.
+func cleanupLocal(store roomStore, opts cleanup.Options) (domain.CleanupResult, error) {
+	if opts.Archive == configs.ArchiveCollection {
+		return domain.CleanupResult{}, errors.New("-archive collection is not supported by the local backend")
+	}
+
+	records, err := store.List()
+	if err != nil {
+		return domain.CleanupResult{}, err
+	}
+
+	now := time.Now()
+	threshold := now.Add(-opts.Retention)
+	var expired []domain.RoomRecord
+	for _, r := range records {
+		if r.Room.UpdatedAt.Before(threshold) {
+			expired = append(expired, r)
+		}
+	}
+
+	result := domain.CleanupResult{DryRun: opts.DryRun, Rooms: []domain.DeletedRoom{}, CleanedAt: now}
+	if !opts.DryRun && opts.Archive == configs.ArchiveFile && len(expired) > 0 {
+		if err := appendArchive(opts.ArchiveFile, expired, now); err != nil {
+			return result, err
+		}
+		result.Archived = len(expired)
+	}
+	for _, r := range expired {
+		if !opts.DryRun {
+			if _, err := store.Delete(r.ID); err != nil {
+				return result, err
+			}
+			result.Deleted++
+		}
+		result.Rooms = append(result.Rooms, domain.DeletedRoom{ID: r.ID, Name: r.Room.Name, UpdatedAt: r.Room.UpdatedAt})
+	}
+
+	result.Message = fmt.Sprintf("deleted %d expired room(s)", result.Deleted)
+	if opts.DryRun {
+		result.Message = fmt.Sprintf("dry run: %d room(s) would be deleted", len(result.Rooms))
+	}
+	return result, nil
+}
+
+func appendArchive(path string, records []domain.RoomRecord, archivedAt time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(domain.NewArchivedRoom(r.ID, r.Room, archivedAt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runRepair(store roomStore, _ string, args []string) error {
+	return rewriteRooms("repair", args, store, (*domain.Room).RepairMemberIndexes)
+}
+
+func runBackfill(store roomStore, _ string, args []string) error {
+	return rewriteRooms("backfill", args, store, (*domain.Room).Backfill)
+}
+
+// rewriteRooms applies fix to every room (or the IDs given) and saves the
+// ones it reports as changed.
+func rewriteRooms(name string, args []string, store roomStore, fix func(*domain.Room) bool) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "report rooms that would change without writing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var records []domain.RoomRecord
+	if fs.NArg() == 0 {
+		all, err := store.List()
+		if err != nil {
+			return err
+		}
+		records = all
+	} else {
+		for _, id := range fs.Args() {
+			room, found, err := store.Get(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("room %s not found", id)
+			}
+			records = append(records, domain.RoomRecord{ID: id, Room: room})
+		}
+	}
+
+	changed := 0
+	for _, r := range records {
+		room := r.Room
+		if !fix(&room) {
+			continue
+		}
+		changed++
+		fmt.Printf("%s: updated%s\n", r.ID, dryRunSuffix(*dryRun))
+		if *dryRun {
+			continue
+		}
+		if err := store.Put(r.ID, room); err != nil {
+			return fmt.Errorf("room %s: %w", r.ID, err)
+		}
+	}
+	fmt.Printf("%s: %d of %d room(s) changed%s\n", name, changed, len(records), dryRunSuffix(*dryRun))
+	return nil
+}
+
+func dryRunSuffix(dryRun bool) string {
+	if dryRun {
+		return " (dry run)"
+	}
+	return ""
+}
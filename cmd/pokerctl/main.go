@@ -0,0 +1,139 @@
+// Command pokerctl is the operator tool for room maintenance. It talks to the
+// same storage the service uses, through the same repository layer.
+//
+// Usage:
+//
+//	pokerctl [global flags] <command> [command flags] [args]
+//
+// Global flags select the storage backend:
+//
+//	-backend firestore   FIREBASE_CREDENTIALS or -credentials-file (default)
+//	-backend emulator    FIRESTORE_EMULATOR_HOST or -emulator-host, plus -project
+//	-backend local       a directory of <roomId>.json files given by -data-dir
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/raksitnongbua/planning-poker-service/internal/repository"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+const (
+	backendFirestore = "firestore"
+	backendEmulator  = "emulator"
+	backendLocal     = "local"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(store roomStore, backend string, args []string) error
+}
+
+var commands = []command{
+	{"list", "List rooms, most recently updated first", runList},
+	{"inspect", "Print a room as JSON", runInspect},
+	{"export", "Export rooms as JSON lines", runExport},
+	{"import", "Import rooms from JSON lines", runImport},
+	{"delete", "Delete rooms by ID", runDelete},
+	{"cleanup", "Delete rooms idle longer than the retention period", runCleanup},
+	{"repair", "Rebuild MemberIDs/EverJoinedMemberIDs from Members", runRepair},
+	{"backfill", "Fill fields missing from documents written by older versions", runBackfill},
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "pokerctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	_ = godotenv.Load(".env")
+	logger.Init("production")
+
+	global := flag.NewFlagSet("pokerctl", flag.ContinueOnError)
+	global.Usage = func() { usage(global) }
+	backend := global.String("backend", backendFirestore, "storage backend: firestore, emulator or local")
+	credentialsFile := global.String("credentials-file", "", "service account JSON file (defaults to FIREBASE_CREDENTIALS)")
+	project := global.String("project", "demo-planning-poker", "project ID for the emulator backend")
+	emulatorHost := global.String("emulator-host", "", "host:port of the Firestore emulator (defaults to FIRESTORE_EMULATOR_HOST)")
+	dataDir := global.String("data-dir", "", "directory for the local backend")
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		usage(global)
+		return errors.New("missing command")
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == rest[0] {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		usage(global)
+		return fmt.Errorf("unknown command %q", rest[0])
+	}
+
+	store, err := openStore(*backend, *credentialsFile, *project, *emulatorHost, *dataDir)
+	if err != nil {
+		return err
+	}
+	return cmd.run(store, *backend, rest[1:])
+}
+
+func openStore(backend, credentialsFile, project, emulatorHost, dataDir string) (roomStore, error) {
+	switch backend {
+	case backendFirestore:
+		credentials := os.Getenv("FIREBASE_CREDENTIALS")
+		if credentialsFile != "" {
+			data, err := os.ReadFile(credentialsFile)
+			if err != nil {
+				return nil, err
+			}
+			credentials = string(data)
+		}
+		if credentials == "" {
+			return nil, errors.New("set FIREBASE_CREDENTIALS or -credentials-file for the firestore backend")
+		}
+		if err := repository.InitWithCredentials(credentials); err != nil {
+			return nil, err
+		}
+		return firestoreStore{}, nil
+	case backendEmulator:
+		if emulatorHost != "" {
+			os.Setenv("FIRESTORE_EMULATOR_HOST", emulatorHost)
+		}
+		if err := repository.InitEmulator(project); err != nil {
+			return nil, err
+		}
+		return firestoreStore{}, nil
+	case backendLocal:
+		return newLocalStore(dataDir)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", backend)
+	}
+}
+
+func usage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: pokerctl [global flags] <command> [command flags] [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(out, "\nGlobal flags:")
+	global.PrintDefaults()
+	fmt.Fprintln(out, "\nRun 'pokerctl <command> -h' for command flags.")
+}
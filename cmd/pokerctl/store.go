@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	repo "github.com/raksitnongbua/planning-poker-service/internal/repository/room"
+)
+
+// roomStore is the storage pokerctl operates on. The Firestore store goes
+// through the service's repository layer so both share document mapping.
+type roomStore interface {
+	List() ([]domain.RoomRecord, error)
+	Get(id string) (domain.Room, bool, error)
+	Put(id string, room domain.Room) error
+	Delete(id string) (bool, error)
+}
+
+type firestoreStore struct{}
+
+func (firestoreStore) List() ([]domain.RoomRecord, error) {
+	return repo.ListRooms(time.Time{}, time.Time{}, nil, math.MaxInt)
+}
+
+func (firestoreStore) Get(id string) (domain.Room, bool, error) {
+	return repo.FindRoom(id)
+}
+
+func (firestoreStore) Put(id string, room domain.Room) error {
+	return repo.SaveRoom(id, room)
+}
+
+func (firestoreStore) Delete(id string) (bool, error) {
+	return repo.DeleteRoom(id, false)
+}
+
+// serverFields are the room fields that never leave the server, and so are
+// left out of Room's JSON. Files pokerctl writes carry them alongside.
+type serverFields struct {
+	PasscodeHash string       `json:"passcode_hash,omitempty"`
+	Bans         []domain.Ban `json:"bans,omitempty"`
+}
+
+func serverFieldsOf(room domain.Room) serverFields {
+	return serverFields{PasscodeHash: room.PasscodeHash, Bans: room.Bans}
+}
+
+func (f serverFields) restore(room *domain.Room) {
+	room.PasscodeHash = f.PasscodeHash
+	room.Bans = f.Bans
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+)
+
+// localStore keeps one <roomId>.json file per room in a directory. It is
+// meant for local development and for rehearsing maintenance runs on an
+// exported copy before touching Firestore.
+type localStore struct {
+	dir string
+}
+
+func newLocalStore(dir string) (localStore, error) {
+	if dir == "" {
+		return localStore{}, errors.New("-data-dir is required for the local backend")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return localStore{}, err
+	}
+	return localStore{dir: dir}, nil
+}
+
+// storedRoom is a room file, including the fields Room keeps out of its JSON.
+type storedRoom struct {
+	domain.Room
+	serverFields
+}
+
+func (s localStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", errors.New("invalid room id: " + id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s localStore) List() ([]domain.RoomRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []domain.RoomRecord{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		room, _, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, domain.RoomRecord{ID: id, Room: room})
+	}
+
+	// Match the Firestore ordering: most recently updated first.
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Room.UpdatedAt.After(records[j].Room.UpdatedAt)
+	})
+	return records, nil
+}
+
+func (s localStore) Get(id string) (domain.Room, bool, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return domain.Room{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return domain.Room{}, false, nil
+	}
+	if err != nil {
+		return domain.Room{}, false, err
+	}
+	var stored storedRoom
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return domain.Room{}, false, err
+	}
+	stored.restore(&stored.Room)
+	return stored.Room, true, nil
+}
+
+func (s localStore) Put(id string, room domain.Room) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(storedRoom{Room: room, serverFields: serverFieldsOf(room)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s localStore) Delete(id string) (bool, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return false, err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -1,23 +1,269 @@
 package configs
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type config struct {
-	FirebaseCredentials string `env:"FIREBASE_CREDENTIALS,required"`
-	AuthSecret          string `env:"NEXTAUTH_SECRET,required"`
+	FirebaseCredentials string `env:"FIREBASE_CREDENTIALS,required" redact:"true"`
+	AuthSecret          string `env:"NEXTAUTH_SECRET,required" redact:"true"`
 	AppEnv              string `env:"APP_ENV" envDefault:"production"`
 	AllowedOrigins      string `env:"ALLOWED_ORIGINS" envDefault:"http://localhost:3000"`
+
+	Port               int           `env:"PORT" envDefault:"8080"`
+	BodyLimit          int           `env:"BODY_LIMIT_BYTES" envDefault:"1048576"`
+	RateLimitMax       int           `env:"RATE_LIMIT_MAX" envDefault:"200"`
+	RateLimitWindow    time.Duration `env:"RATE_LIMIT_WINDOW" envDefault:"1m"`
+	WSReadBufferSize   int           `env:"WS_READ_BUFFER_SIZE" envDefault:"1024"`
+	WSWriteBufferSize  int           `env:"WS_WRITE_BUFFER_SIZE" envDefault:"1024"`
+	WSReplayBuffer     int           `env:"WS_REPLAY_BUFFER" envDefault:"256"`
+	WSReplayRetention  time.Duration `env:"WS_REPLAY_RETENTION" envDefault:"10m"`
+	WSPingInterval     time.Duration `env:"WS_PING_INTERVAL" envDefault:"30s"`
+	CORSMaxAge         time.Duration `env:"CORS_MAX_AGE" envDefault:"24h"`
+	RoomRetention      time.Duration `env:"ROOM_RETENTION" envDefault:"720h"`
+	ReadinessTimeout   time.Duration `env:"READINESS_TIMEOUT" envDefault:"2s"`
+	ShutdownDrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" envDefault:"5s"`
+	ShutdownTimeout    time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s"`
+
+	PresenceAwayAfter       time.Duration `env:"PRESENCE_AWAY_AFTER" envDefault:"60s"`
+	PresenceOfflineAfter    time.Duration `env:"PRESENCE_OFFLINE_AFTER" envDefault:"75s"`
+	PresencePersistInterval time.Duration `env:"PRESENCE_PERSIST_INTERVAL" envDefault:"5m"`
+
+	AdminToken     string `env:"ADMIN_TOKEN" redact:"true"`
+	AdminJWTSecret string `env:"ADMIN_JWT_SECRET" redact:"true"`
+
+	InviteSecret       string        `env:"INVITE_SECRET" redact:"true"`
+	InviteTTL          time.Duration `env:"INVITE_TTL" envDefault:"168h"`
+	JoinAttemptsMax    int           `env:"JOIN_ATTEMPTS_MAX" envDefault:"5"`
+	JoinAttemptsWindow time.Duration `env:"JOIN_ATTEMPTS_WINDOW" envDefault:"15m"`
+
+	RoundHistoryLimit int `env:"ROUND_HISTORY_LIMIT" envDefault:"200"`
+
+	AsyncMaxDuration   time.Duration `env:"ASYNC_MAX_DURATION" envDefault:"336h"`
+	AsyncCheckInterval time.Duration `env:"ASYNC_CHECK_INTERVAL" envDefault:"1m"`
+
+	CleanupEnabled     bool          `env:"CLEANUP_ENABLED" envDefault:"true"`
+	CleanupInterval    time.Duration `env:"CLEANUP_INTERVAL" envDefault:"1h"`
+	CleanupBatchSize   int           `env:"CLEANUP_BATCH_SIZE" envDefault:"100"`
+	CleanupArchive     string        `env:"CLEANUP_ARCHIVE" envDefault:"none"`
+	CleanupArchiveFile string        `env:"CLEANUP_ARCHIVE_FILE" envDefault:"archive/rooms.jsonl"`
+	CleanupDryRun      bool          `env:"CLEANUP_DRY_RUN" envDefault:"false"`
 }
 
+// Archive modes for CLEANUP_ARCHIVE.
+const (
+	ArchiveNone       = "none"
+	ArchiveCollection = "collection"
+	ArchiveFile       = "file"
+)
+
+// configFileEnv points at an optional YAML or TOML file whose flat keys are
+// the same names as the environment variables. Real env vars always win.
+const configFileEnv = "CONFIG_FILE"
+
 var Conf config
 
 func Init() {
 	// Load .env if present (local dev). Ignore error in production.
 	_ = godotenv.Load(".env")
-	if err := env.Parse(&Conf); err != nil {
+	c, err := load(os.Getenv(configFileEnv), env.ToMap(os.Environ()))
+	if err != nil {
 		panic(err.Error())
 	}
+	Conf = c
+}
+
+func load(configFile string, environ map[string]string) (config, error) {
+	merged := map[string]string{}
+	if configFile != "" {
+		fileValues, err := readConfigFile(configFile)
+		if err != nil {
+			return config{}, err
+		}
+		for k, v := range fileValues {
+			merged[k] = v
+		}
+	}
+	for k, v := range environ {
+		merged[k] = v
+	}
+
+	var c config
+	if err := env.ParseWithOptions(&c, env.Options{Environment: merged}); err != nil {
+		return config{}, err
+	}
+	if err := c.Validate(); err != nil {
+		return config{}, err
+	}
+	return c, nil
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (want .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file: %w", err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		values[strings.ToUpper(k)] = fmt.Sprint(v)
+	}
+	return values, nil
+}
+
+// Validate rejects values that would leave the server unusable or unsafe.
+func (c *config) Validate() error {
+	var errs []error
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.BodyLimit < 1024 || c.BodyLimit > 64*1024*1024 {
+		errs = append(errs, fmt.Errorf("BODY_LIMIT_BYTES must be between 1KB and 64MB, got %d", c.BodyLimit))
+	}
+	if c.RateLimitMax < 1 {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_MAX must be positive, got %d", c.RateLimitMax))
+	}
+	if c.RateLimitWindow < time.Second {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_WINDOW must be at least 1s, got %s", c.RateLimitWindow))
+	}
+	if c.WSReadBufferSize < 256 || c.WSReadBufferSize > 1024*1024 {
+		errs = append(errs, fmt.Errorf("WS_READ_BUFFER_SIZE must be between 256 and 1048576, got %d", c.WSReadBufferSize))
+	}
+	if c.WSWriteBufferSize < 256 || c.WSWriteBufferSize > 1024*1024 {
+		errs = append(errs, fmt.Errorf("WS_WRITE_BUFFER_SIZE must be between 256 and 1048576, got %d", c.WSWriteBufferSize))
+	}
+	if c.WSReplayBuffer < 0 || c.WSReplayBuffer > 10000 {
+		errs = append(errs, fmt.Errorf("WS_REPLAY_BUFFER must be between 0 and 10000, got %d", c.WSReplayBuffer))
+	}
+	if c.WSReplayRetention < 0 {
+		errs = append(errs, fmt.Errorf("WS_REPLAY_RETENTION must not be negative, got %s", c.WSReplayRetention))
+	}
+	if c.WSPingInterval < time.Second {
+		errs = append(errs, fmt.Errorf("WS_PING_INTERVAL must be at least 1s, got %s", c.WSPingInterval))
+	}
+	if c.PresenceAwayAfter <= 0 {
+		errs = append(errs, fmt.Errorf("PRESENCE_AWAY_AFTER must be positive, got %s", c.PresenceAwayAfter))
+	}
+	// A connection must get at least one ping in before it is given up on.
+	if c.PresenceOfflineAfter <= c.WSPingInterval {
+		errs = append(errs, fmt.Errorf("PRESENCE_OFFLINE_AFTER (%s) must be longer than WS_PING_INTERVAL (%s)", c.PresenceOfflineAfter, c.WSPingInterval))
+	}
+	// An idle connection is away before it is dropped, not the other way round.
+	if c.PresenceAwayAfter >= c.PresenceOfflineAfter {
+		errs = append(errs, fmt.Errorf("PRESENCE_AWAY_AFTER (%s) must be shorter than PRESENCE_OFFLINE_AFTER (%s)", c.PresenceAwayAfter, c.PresenceOfflineAfter))
+	}
+	if c.PresencePersistInterval < time.Second {
+		errs = append(errs, fmt.Errorf("PRESENCE_PERSIST_INTERVAL must be at least 1s, got %s", c.PresencePersistInterval))
+	}
+	// Invite tokens are HMAC-SHA256 signed; a short key makes them guessable.
+	if c.InviteSecret != "" && len(c.InviteSecret) < 32 {
+		errs = append(errs, fmt.Errorf("INVITE_SECRET must be at least 32 bytes, got %d", len(c.InviteSecret)))
+	}
+	if c.InviteTTL < time.Minute {
+		errs = append(errs, fmt.Errorf("INVITE_TTL must be at least 1m, got %s", c.InviteTTL))
+	}
+	if c.JoinAttemptsMax < 1 {
+		errs = append(errs, fmt.Errorf("JOIN_ATTEMPTS_MAX must be positive, got %d", c.JoinAttemptsMax))
+	}
+	if c.JoinAttemptsWindow < time.Second {
+		errs = append(errs, fmt.Errorf("JOIN_ATTEMPTS_WINDOW must be at least 1s, got %s", c.JoinAttemptsWindow))
+	}
+	// The history lives in the room document, which Firestore caps at 1 MiB.
+	if c.RoundHistoryLimit < 0 || c.RoundHistoryLimit > 1000 {
+		errs = append(errs, fmt.Errorf("ROUND_HISTORY_LIMIT must be between 0 and 1000, got %d", c.RoundHistoryLimit))
+	}
+	if c.AsyncMaxDuration < time.Hour {
+		errs = append(errs, fmt.Errorf("ASYNC_MAX_DURATION must be at least 1h, got %s", c.AsyncMaxDuration))
+	}
+	if c.AsyncCheckInterval < 10*time.Second {
+		errs = append(errs, fmt.Errorf("ASYNC_CHECK_INTERVAL must be at least 10s, got %s", c.AsyncCheckInterval))
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("CORS_MAX_AGE must not be negative, got %s", c.CORSMaxAge))
+	}
+	if c.RoomRetention < 24*time.Hour {
+		errs = append(errs, fmt.Errorf("ROOM_RETENTION must be at least 24h, got %s", c.RoomRetention))
+	}
+	if c.ReadinessTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("READINESS_TIMEOUT must be positive, got %s", c.ReadinessTimeout))
+	}
+	if c.ShutdownDrainDelay < 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_DRAIN_DELAY must not be negative, got %s", c.ShutdownDrainDelay))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT must be positive, got %s", c.ShutdownTimeout))
+	}
+	if c.CleanupInterval < time.Minute {
+		errs = append(errs, fmt.Errorf("CLEANUP_INTERVAL must be at least 1m, got %s", c.CleanupInterval))
+	}
+	// Each room costs two transaction writes when archiving; Firestore caps a transaction at 500.
+	if c.CleanupBatchSize < 1 || c.CleanupBatchSize > 250 {
+		errs = append(errs, fmt.Errorf("CLEANUP_BATCH_SIZE must be between 1 and 250, got %d", c.CleanupBatchSize))
+	}
+	switch c.CleanupArchive {
+	case ArchiveNone, ArchiveCollection:
+	case ArchiveFile:
+		if c.CleanupArchiveFile == "" {
+			errs = append(errs, errors.New("CLEANUP_ARCHIVE_FILE is required when CLEANUP_ARCHIVE=file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CLEANUP_ARCHIVE must be one of none, collection, file, got %q", c.CleanupArchive))
+	}
+	return errors.Join(errs...)
+}
+
+// Print writes the effective configuration as sorted KEY=value lines.
+// Fields tagged redact:"true" are masked so secrets never reach the logs.
+func (c config) Print(w io.Writer) {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	lines := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		value := fmt.Sprint(v.Field(i).Interface())
+		if field.Tag.Get("redact") == "true" {
+			value = redact(value)
+		}
+		lines = append(lines, name+"="+value)
+	}
+	sort.Strings(lines)
+
+	fmt.Fprintln(w, "effective config:")
+	for _, line := range lines {
+		fmt.Fprintln(w, "  "+line)
+	}
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "[REDACTED]"
 }
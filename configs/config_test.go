@@ -0,0 +1,153 @@
+package configs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func requiredEnv() map[string]string {
+	return map[string]string{
+		"FIREBASE_CREDENTIALS": `{"type":"service_account"}`,
+		"NEXTAUTH_SECRET":      "super-secret",
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := load("", requiredEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Port != 8080 {
+		t.Errorf("expected Port 8080, got %d", c.Port)
+	}
+	if c.BodyLimit != 1024*1024 {
+		t.Errorf("expected BodyLimit 1MB, got %d", c.BodyLimit)
+	}
+	if c.RateLimitMax != 200 || c.RateLimitWindow != time.Minute {
+		t.Errorf("expected 200 req/min, got %d per %s", c.RateLimitMax, c.RateLimitWindow)
+	}
+	if c.RoomRetention != 30*24*time.Hour {
+		t.Errorf("expected RoomRetention 30 days, got %s", c.RoomRetention)
+	}
+}
+
+func TestLoad_YAMLFileUnderEnv(t *testing.T) {
+	path := writeFile(t, "config.yaml", "port: 9090\nrate_limit_max: 50\nroom_retention: 168h\n")
+	environ := requiredEnv()
+	environ["RATE_LIMIT_MAX"] = "75"
+
+	c, err := load(path, environ)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Port != 9090 {
+		t.Errorf("expected Port from file 9090, got %d", c.Port)
+	}
+	if c.RateLimitMax != 75 {
+		t.Errorf("expected env var to override file, got %d", c.RateLimitMax)
+	}
+	if c.RoomRetention != 7*24*time.Hour {
+		t.Errorf("expected RoomRetention 168h, got %s", c.RoomRetention)
+	}
+}
+
+func TestLoad_TOMLFile(t *testing.T) {
+	path := writeFile(t, "config.toml", "PORT = 7000\nWS_READ_BUFFER_SIZE = 4096\n")
+
+	c, err := load(path, requiredEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Port != 7000 || c.WSReadBufferSize != 4096 {
+		t.Errorf("expected values from TOML file, got port=%d read_buffer=%d", c.Port, c.WSReadBufferSize)
+	}
+}
+
+func TestLoad_UnsupportedExtension(t *testing.T) {
+	path := writeFile(t, "config.json", "{}")
+
+	if _, err := load(path, requiredEnv()); err == nil {
+		t.Error("expected error for unsupported config file extension")
+	}
+}
+
+func TestLoad_InvalidValuesRejected(t *testing.T) {
+	environ := requiredEnv()
+	environ["PORT"] = "70000"
+	environ["RATE_LIMIT_MAX"] = "0"
+
+	_, err := load("", environ)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !strings.Contains(err.Error(), "PORT") || !strings.Contains(err.Error(), "RATE_LIMIT_MAX") {
+		t.Errorf("expected both invalid fields reported, got %v", err)
+	}
+}
+
+func TestLoad_PresenceTimeoutsOrdered(t *testing.T) {
+	environ := requiredEnv()
+	environ["PRESENCE_AWAY_AFTER"] = "2m"
+	environ["PRESENCE_OFFLINE_AFTER"] = "75s"
+	environ["WS_PING_INTERVAL"] = "90s"
+
+	_, err := load("", environ)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !strings.Contains(err.Error(), "PRESENCE_AWAY_AFTER") || !strings.Contains(err.Error(), "WS_PING_INTERVAL") {
+		t.Errorf("expected both presence timeouts reported, got %v", err)
+	}
+}
+
+func TestLoad_InvalidCleanupArchiveRejected(t *testing.T) {
+	environ := requiredEnv()
+	environ["CLEANUP_ARCHIVE"] = "s3"
+
+	if _, err := load("", environ); err == nil {
+		t.Error("expected error for unknown CLEANUP_ARCHIVE mode")
+	}
+}
+
+func TestLoad_ShortInviteSecretRejected(t *testing.T) {
+	environ := requiredEnv()
+	environ["INVITE_SECRET"] = "too-short"
+
+	if _, err := load("", environ); err == nil || !strings.Contains(err.Error(), "INVITE_SECRET") {
+		t.Errorf("expected INVITE_SECRET error, got %v", err)
+	}
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	c, err := load("", requiredEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	c.Print(&buf)
+	out := buf.String()
+
+	if strings.Contains(out, "super-secret") || strings.Contains(out, "service_account") {
+		t.Errorf("expected secrets to be redacted, got:\n%s", out)
+	}
+	if !strings.Contains(out, "NEXTAUTH_SECRET=[REDACTED]") {
+		t.Errorf("expected redacted NEXTAUTH_SECRET line, got:\n%s", out)
+	}
+	if !strings.Contains(out, "PORT=8080") {
+		t.Errorf("expected PORT line, got:\n%s", out)
+	}
+}
@@ -0,0 +1,71 @@
+package adminauth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// RequireAdmin is a Fiber middleware that only lets through requests carrying
+// either the configured ADMIN_TOKEN or an HS256 JWT signed with
+// ADMIN_JWT_SECRET as a bearer token. When neither is configured the admin
+// endpoints are disabled entirely.
+func RequireAdmin(c *fiber.Ctx) error {
+	staticToken := configs.Conf.AdminToken
+	jwtSecret := configs.Conf.AdminJWTSecret
+	if staticToken == "" && jwtSecret == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin API is disabled"})
+	}
+
+	token, ok := bearerToken(c.Get(fiber.HeaderAuthorization))
+	if !ok {
+		return unauthorized(c)
+	}
+
+	if staticToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(staticToken)) == 1 {
+		c.Locals("admin_subject", "static-token")
+		return c.Next()
+	}
+
+	if jwtSecret != "" {
+		subject, err := verifyAdminJWT(token, jwtSecret)
+		if err == nil {
+			c.Locals("admin_subject", subject)
+			return c.Next()
+		}
+		logger.Warn("admin jwt rejected", "ip", c.IP(), "error", err)
+	}
+	return unauthorized(c)
+}
+
+// verifyAdminJWT checks the signature and the exp claim, which must be present
+// so that leaked tokens cannot be used forever. Returns the token subject.
+func verifyAdminJWT(token, secret string) (string, error) {
+	parsed, err := jwt.Parse([]byte(token),
+		jwt.WithKey(jwa.HS256, []byte(secret)),
+		jwt.WithValidate(true),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Subject(), nil
+}
+
+func unauthorized(c *fiber.Ctx) error {
+	logger.Warn("admin auth failed", "ip", c.IP(), "path", c.Path())
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -0,0 +1,102 @@
+package adminauth
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("test")
+	os.Exit(m.Run())
+}
+
+func newTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/admin", RequireAdmin, func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+func doRequest(t *testing.T, authorization string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/admin", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := newTestApp().Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func signToken(t *testing.T, secret string, withExp bool) string {
+	t.Helper()
+	builder := jwt.NewBuilder().Subject("ops-bot")
+	if withExp {
+		builder = builder.Expiration(time.Now().Add(time.Hour))
+	}
+	tok, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, []byte(secret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func setAdminConfig(t *testing.T, token, jwtSecret string) {
+	t.Helper()
+	prevToken, prevSecret := configs.Conf.AdminToken, configs.Conf.AdminJWTSecret
+	configs.Conf.AdminToken, configs.Conf.AdminJWTSecret = token, jwtSecret
+	t.Cleanup(func() {
+		configs.Conf.AdminToken, configs.Conf.AdminJWTSecret = prevToken, prevSecret
+	})
+}
+
+func TestRequireAdmin_DisabledWithoutCredentials(t *testing.T) {
+	setAdminConfig(t, "", "")
+
+	if code := doRequest(t, "Bearer anything"); code != fiber.StatusForbidden {
+		t.Errorf("expected 403, got %d", code)
+	}
+}
+
+func TestRequireAdmin_StaticToken(t *testing.T) {
+	setAdminConfig(t, "s3cret", "")
+
+	if code := doRequest(t, "Bearer s3cret"); code != fiber.StatusOK {
+		t.Errorf("expected 200 for matching token, got %d", code)
+	}
+	if code := doRequest(t, "Bearer wrong"); code != fiber.StatusUnauthorized {
+		t.Errorf("expected 401 for wrong token, got %d", code)
+	}
+	if code := doRequest(t, ""); code != fiber.StatusUnauthorized {
+		t.Errorf("expected 401 without header, got %d", code)
+	}
+}
+
+func TestRequireAdmin_SignedJWT(t *testing.T) {
+	setAdminConfig(t, "", "jwt-secret")
+
+	if code := doRequest(t, "Bearer "+signToken(t, "jwt-secret", true)); code != fiber.StatusOK {
+		t.Errorf("expected 200 for valid JWT, got %d", code)
+	}
+	if code := doRequest(t, "Bearer "+signToken(t, "other-secret", true)); code != fiber.StatusUnauthorized {
+		t.Errorf("expected 401 for JWT with wrong signature, got %d", code)
+	}
+	if code := doRequest(t, "Bearer "+signToken(t, "jwt-secret", false)); code != fiber.StatusUnauthorized {
+		t.Errorf("expected 401 for JWT without exp, got %d", code)
+	}
+}
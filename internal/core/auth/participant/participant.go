@@ -0,0 +1,41 @@
+package participantauth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	websocketauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/websocket"
+)
+
+// Identify resolves who is calling a room endpoint from the session or guest
+// cookie and stores it in the "authenticated_uid" local, like the /ws
+// middleware does. Callers without one are let through anonymously with an
+// empty uid. Unlike the socket URL during the cookie auth rollout, REST takes
+// no uid from the caller: anyone knowing a member's ID could act as them.
+func Identify(c *fiber.Ctx) error {
+	c.Locals("authenticated_uid", resolveUID(c))
+	return c.Next()
+}
+
+// RequireParticipant is Identify for endpoints that act on behalf of a
+// member, rejecting anonymous callers.
+func RequireParticipant(c *fiber.Ctx) error {
+	uid := resolveUID(c)
+	if uid == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	c.Locals("authenticated_uid", uid)
+	return c.Next()
+}
+
+// UID returns the uid Identify resolved, or "" for anonymous callers.
+func UID(c *fiber.Ctx) string {
+	uid, _ := c.Locals("authenticated_uid").(string)
+	return uid
+}
+
+func resolveUID(c *fiber.Ctx) string {
+	uid, err := websocketauth.ExtractAuthenticatedUID(c)
+	if err != nil {
+		return ""
+	}
+	return uid
+}
@@ -0,0 +1,42 @@
+package participantauth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/", RequireParticipant, func(c *fiber.Ctx) error {
+		return c.SendString(UID(c))
+	})
+	return app
+}
+
+func TestRequireParticipant_IgnoresCallerChosenUID(t *testing.T) {
+	req := httptest.NewRequest("POST", "/?uid=owner", nil)
+	req.Header.Set("X-User-ID", "owner")
+
+	resp, err := newApp().Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("expected 401 without a cookie, got %d", resp.StatusCode)
+	}
+}
+
+func TestRequireParticipant_AcceptsGuestCookie(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Cookie", "CPPUniID=guest-1")
+
+	resp, err := newApp().Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("expected 200 with the guest cookie, got %d", resp.StatusCode)
+	}
+}
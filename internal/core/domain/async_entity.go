@@ -0,0 +1,260 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxAsyncTickets bounds the tickets of one AsyncSession.
+const MaxAsyncTickets = 50
+
+var (
+	ErrAsyncInProgress = errors.New("an async session is already open")
+	ErrNoAsyncSession  = errors.New("no async session is open")
+	// ErrAsyncTicketNotFound and ErrNoAsyncTickets refuse tickets that are
+	// not in the queue, or not in the session.
+	ErrAsyncTicketNotFound = errors.New("no such ticket")
+	ErrNoAsyncTickets      = errors.New("no tickets to estimate")
+	ErrAsyncTicketRevealed = errors.New("the ticket's votes are revealed")
+	// ErrAsyncMultiDimensional refuses an async session in a room that votes
+	// on dimensions.
+	ErrAsyncMultiDimensional = errors.New("async estimation takes single-value voting")
+)
+
+// AsyncSession is an asynchronous estimation of tickets from the queue:
+// members vote on each whenever they like until the deadline. A ticket is
+// revealed as soon as every member has voted on it, and the rest at the
+// deadline; the session closes once all are revealed.
+type AsyncSession struct {
+	StartedAt time.Time     `json:"started_at" firestore:"StartedAt"`
+	Deadline  time.Time     `json:"deadline" firestore:"Deadline"`
+	Tickets   []AsyncTicket `json:"tickets" firestore:"Tickets"`
+}
+
+// AsyncTicket is one ticket of an AsyncSession. Votes, member ID to card,
+// are hidden like a round's until the ticket is revealed.
+type AsyncTicket struct {
+	Ticket TicketEstimation  `json:"ticket" firestore:"Ticket"`
+	Votes  map[string]string `json:"votes" firestore:"Votes"`
+	// Voted lists who has voted; derived by Room.ViewFor and never stored.
+	Voted      []string       `json:"voted" firestore:"-"`
+	Result     map[string]int `json:"result" firestore:"Result"`
+	RevealedAt *time.Time     `json:"revealed_at" firestore:"RevealedAt"`
+}
+
+// StartAsync opens an async session on the queued tickets with the given
+// Jira keys or names, or on every unscored one when keys is empty.
+func (r *Room) StartAsync(keys []string, deadline, now time.Time) error {
+	if r.Async != nil {
+		return ErrAsyncInProgress
+	}
+	if r.MultiDimensional() {
+		return ErrAsyncMultiDimensional
+	}
+
+	var tickets []AsyncTicket
+	if len(keys) == 0 {
+		for _, t := range r.TicketQueue {
+			if t.AvgScore == 0 && t.FinalScore == "" {
+				tickets = append(tickets, AsyncTicket{Ticket: t})
+			}
+		}
+	}
+	added := map[string]bool{}
+	for _, key := range keys {
+		i := r.queueIndex(key)
+		if i < 0 {
+			return fmt.Errorf("%w: %q is not in the queue", ErrAsyncTicketNotFound, key)
+		}
+		if !added[key] {
+			added[key] = true
+			tickets = append(tickets, AsyncTicket{Ticket: r.TicketQueue[i]})
+		}
+	}
+	if len(tickets) == 0 {
+		return ErrNoAsyncTickets
+	}
+	if len(tickets) > MaxAsyncTickets {
+		return fmt.Errorf("%w: at most %d", ErrNoAsyncTickets, MaxAsyncTickets)
+	}
+
+	r.Async = &AsyncSession{StartedAt: now, Deadline: deadline, Tickets: tickets}
+	r.UpdatedAt = now
+	return nil
+}
+
+// AsyncVote records the card of the member at index on a ticket of the
+// session; an empty card withdraws the vote. When every member has voted the
+// ticket is revealed, and the round it adds to the history is returned.
+func (r *Room) AsyncVote(index int, key, value string, historyLimit int, now time.Time) ([]Round, error) {
+	if r.Async == nil {
+		return nil, ErrNoAsyncSession
+	}
+	i := r.Async.ticketIndex(key)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q is not in the session", ErrAsyncTicketNotFound, key)
+	}
+	ticket := &r.Async.Tickets[i]
+	if ticket.RevealedAt != nil {
+		return nil, ErrAsyncTicketRevealed
+	}
+
+	memberID := r.Members[index].ID
+	if value == "" {
+		delete(ticket.Votes, memberID)
+	} else {
+		if ticket.Votes == nil {
+			ticket.Votes = map[string]string{}
+		}
+		ticket.Votes[memberID] = value
+	}
+	r.UpdatedAt = now
+
+	for _, m := range r.Members {
+		if ticket.Votes[m.ID] == "" {
+			return nil, nil
+		}
+	}
+	rounds := []Round{r.revealAsyncTicket(i, historyLimit, now)}
+	r.closeAsyncIfRevealed()
+	return rounds, nil
+}
+
+// CloseAsync reveals every ticket of the session still open and closes it,
+// returning the rounds it adds to the history.
+func (r *Room) CloseAsync(historyLimit int, now time.Time) ([]Round, error) {
+	if r.Async == nil {
+		return nil, ErrNoAsyncSession
+	}
+	var rounds []Round
+	for i, t := range r.Async.Tickets {
+		if t.RevealedAt == nil {
+			rounds = append(rounds, r.revealAsyncTicket(i, historyLimit, now))
+		}
+	}
+	r.Async = nil
+	r.UpdatedAt = now
+	return rounds, nil
+}
+
+// revealAsyncTicket counts the ticket's votes and stamps its average, final
+// score and whether it needs a live discussion onto it and its queue entry.
+func (r *Room) revealAsyncTicket(i, historyLimit int, now time.Time) Round {
+	ticket := &r.Async.Tickets[i]
+	revealedAt := now
+	ticket.RevealedAt = &revealedAt
+	ticket.Result = map[string]int{}
+	var sum float64
+	var numeric []float64
+	for _, value := range ticket.Votes {
+		ticket.Result[value]++
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			sum += v
+			numeric = append(numeric, v)
+		}
+	}
+
+	ticket.Ticket.AvgScore = 0
+	ticket.Ticket.FinalScore = ""
+	if len(numeric) > 0 {
+		ticket.Ticket.AvgScore = math.Round(sum/float64(len(numeric))*10) / 10
+		ticket.Ticket.FinalScore = nearestDeckOption(r.DeskConfig, ticket.Ticket.AvgScore)
+	}
+	ticket.Ticket.NeedsDiscussion = len(numeric) == 0 || len(numeric) < len(ticket.Votes) || r.spreadsOverCards(numeric)
+	if q := r.queueIndex(ticketKey(&ticket.Ticket)); q >= 0 {
+		r.TicketQueue[q].AvgScore = ticket.Ticket.AvgScore
+		r.TicketQueue[q].FinalScore = ticket.Ticket.FinalScore
+		r.TicketQueue[q].NeedsDiscussion = ticket.Ticket.NeedsDiscussion
+	}
+
+	round := Round{
+		Ticket:     roundTicket(ticket.Ticket),
+		RevealedAt: revealedAt,
+		Result:     ticket.Result,
+		Anonymous:  r.AnonymousVoting,
+		Async:      true,
+	}
+	if !r.AnonymousVoting || r.RecordVotes {
+		round.Votes = ticket.Votes
+	}
+	if historyLimit <= 0 {
+		return round
+	}
+	r.addRound(round, historyLimit)
+	return round
+}
+
+// spreadsOverCards reports whether the votes are further apart than two
+// neighbouring numeric cards of the deck.
+func (r *Room) spreadsOverCards(votes []float64) bool {
+	sort.Float64s(votes)
+	low, high := votes[0], votes[len(votes)-1]
+	for _, card := range DeckCards(r.DeskConfig) {
+		if v, err := strconv.ParseFloat(card, 64); err == nil && v > low && v < high {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Room) closeAsyncIfRevealed() {
+	for _, t := range r.Async.Tickets {
+		if t.RevealedAt == nil {
+			return
+		}
+	}
+	r.Async = nil
+}
+
+// view hides the votes of tickets not revealed yet, and of anonymous rooms,
+// from everyone but their voter.
+func (s AsyncSession) view(uid string, anonymous bool) *AsyncSession {
+	view := s
+	view.Tickets = make([]AsyncTicket, len(s.Tickets))
+	for i, t := range s.Tickets {
+		t.Voted = make([]string, 0, len(t.Votes))
+		for id := range t.Votes {
+			t.Voted = append(t.Voted, id)
+		}
+		sort.Strings(t.Voted)
+		if t.RevealedAt == nil || anonymous {
+			votes := map[string]string{}
+			if value, ok := t.Votes[uid]; ok {
+				votes[uid] = value
+			}
+			t.Votes = votes
+		}
+		view.Tickets[i] = t
+	}
+	return &view
+}
+
+func (s *AsyncSession) ticketIndex(key string) int {
+	for i := range s.Tickets {
+		if ticketKey(&s.Tickets[i].Ticket) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Room) queueIndex(key string) int {
+	for i := range r.TicketQueue {
+		if ticketKey(&r.TicketQueue[i]) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// ticketKey identifies a ticket by its Jira key, or its name without one.
+func ticketKey(t *TicketEstimation) string {
+	if t.JiraKey != "" {
+		return t.JiraKey
+	}
+	return t.Name
+}
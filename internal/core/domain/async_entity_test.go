@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func asyncRoom() *Room {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3,5,8,13"
+	room.Members = []Member{makeMember("a", ""), makeMember("b", ""), makeMember("c", "")}
+	room.TicketQueue = []TicketEstimation{{Name: "T-1"}, {Name: "T-2"}, {Name: "T-3", FinalScore: "5"}}
+	return room
+}
+
+func TestStartAsync_TakesUnscoredTickets(t *testing.T) {
+	room := asyncRoom()
+	now := time.Now()
+
+	if err := room.StartAsync(nil, now.Add(time.Hour), now); err != nil {
+		t.Fatal(err)
+	}
+	if len(room.Async.Tickets) != 2 || room.Async.Tickets[1].Ticket.Name != "T-2" {
+		t.Errorf("expected T-1 and T-2, got %+v", room.Async.Tickets)
+	}
+	if err := room.StartAsync(nil, now.Add(time.Hour), now); err != ErrAsyncInProgress {
+		t.Errorf("expected ErrAsyncInProgress, got %v", err)
+	}
+}
+
+func TestStartAsync_RefusesUnknownTicket(t *testing.T) {
+	room := asyncRoom()
+	now := time.Now()
+
+	err := room.StartAsync([]string{"T-9"}, now.Add(time.Hour), now)
+	if !errors.Is(err, ErrAsyncTicketNotFound) || room.Async != nil {
+		t.Errorf("expected ErrAsyncTicketNotFound, got %v", err)
+	}
+}
+
+func TestAsyncVote_RevealsOnceEveryoneVoted(t *testing.T) {
+	room := asyncRoom()
+	now := time.Now()
+	room.StartAsync([]string{"T-1", "T-2"}, now.Add(time.Hour), now)
+
+	room.AsyncVote(0, "T-1", "3", 10, now)
+	rounds, err := room.AsyncVote(1, "T-1", "3", 10, now)
+	if err != nil || rounds != nil {
+		t.Fatalf("expected T-1 still open, got %v %v", rounds, err)
+	}
+
+	view := room.ViewFor("a")
+	ticket := view.Async.Tickets[0]
+	if len(ticket.Votes) != 1 || ticket.Votes["a"] != "3" || len(ticket.Voted) != 2 {
+		t.Errorf("expected only a's own vote and two voters, got %+v", ticket)
+	}
+
+	rounds, _ = room.AsyncVote(2, "T-1", "5", 10, now)
+	if len(rounds) != 1 || !rounds[0].Async || len(room.Rounds) != 1 {
+		t.Fatalf("expected the reveal to add an async round, got %+v", rounds)
+	}
+	if q := room.TicketQueue[0]; q.AvgScore != 3.7 || q.FinalScore != "3" || q.NeedsDiscussion {
+		t.Errorf("unexpected stamp %+v", q)
+	}
+	if _, err := room.AsyncVote(0, "T-1", "8", 10, now); err != ErrAsyncTicketRevealed {
+		t.Errorf("expected ErrAsyncTicketRevealed, got %v", err)
+	}
+	if len(room.ViewFor("a").Async.Tickets[0].Votes) != 3 {
+		t.Error("expected every vote once revealed")
+	}
+}
+
+func TestCloseAsync_RevealsTheRestAndFlagsSplitVotes(t *testing.T) {
+	room := asyncRoom()
+	now := time.Now()
+	room.StartAsync([]string{"T-1", "T-2"}, now.Add(time.Hour), now)
+	room.AsyncVote(0, "T-1", "1", 10, now)
+	room.AsyncVote(1, "T-1", "8", 10, now)
+
+	rounds, err := room.CloseAsync(10, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 2 || room.Async != nil {
+		t.Fatalf("expected both tickets revealed and the session closed, got %d rounds", len(rounds))
+	}
+	if !room.TicketQueue[0].NeedsDiscussion {
+		t.Error("expected votes across three cards to need discussion")
+	}
+	if !room.TicketQueue[1].NeedsDiscussion || room.TicketQueue[1].FinalScore != "" {
+		t.Errorf("expected a ticket without votes to need discussion, got %+v", room.TicketQueue[1])
+	}
+	if _, err := room.CloseAsync(10, now); err != ErrNoAsyncSession {
+		t.Errorf("expected ErrNoAsyncSession, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+// Ban keeps a user out of a room until ExpiresAt. Bans are kept out of the
+// room's JSON; owners list them through their own endpoint.
+type Ban struct {
+	UserID    string    `json:"user_id" firestore:"UserID"`
+	BannedBy  string    `json:"banned_by" firestore:"BannedBy"`
+	CreatedAt time.Time `json:"created_at" firestore:"CreatedAt"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"ExpiresAt"`
+}
+
+// Ban keeps userID out of the room until expiresAt, replacing any ban they
+// already had. Expired bans are dropped on the way.
+func (r *Room) Ban(userID, bannedBy string, now, expiresAt time.Time) {
+	bans := make([]Ban, 0, len(r.Bans)+1)
+	for _, b := range r.Bans {
+		if b.UserID != userID && b.ExpiresAt.After(now) {
+			bans = append(bans, b)
+		}
+	}
+	r.Bans = append(bans, Ban{UserID: userID, BannedBy: bannedBy, CreatedAt: now, ExpiresAt: expiresAt})
+}
+
+// LiftBan removes userID's ban. Returns false if they had none.
+func (r *Room) LiftBan(userID string) bool {
+	for i, b := range r.Bans {
+		if b.UserID == userID {
+			r.Bans = append(r.Bans[:i:i], r.Bans[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveBans returns the bans that have not expired at now.
+func (r *Room) ActiveBans(now time.Time) []Ban {
+	active := []Ban{}
+	for _, b := range r.Bans {
+		if b.ExpiresAt.After(now) {
+			active = append(active, b)
+		}
+	}
+	return active
+}
+
+// BannedUntil returns when uid's ban ends, and false if they are not banned
+// at now.
+func (r *Room) BannedUntil(uid string, now time.Time) (time.Time, bool) {
+	for _, b := range r.Bans {
+		if b.UserID == uid && b.ExpiresAt.After(now) {
+			return b.ExpiresAt, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBan_ReplacesExistingBanAndDropsExpired(t *testing.T) {
+	now := time.Now()
+	room := makeRoom()
+	room.Ban("old", "owner", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	room.Ban("a", "owner", now, now.Add(time.Hour))
+	room.Ban("a", "owner", now, now.Add(2*time.Hour))
+
+	if len(room.Bans) != 1 {
+		t.Fatalf("expected one ban left, got %+v", room.Bans)
+	}
+	if until, banned := room.BannedUntil("a", now); !banned || !until.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("expected the later ban to win, got %v %v", until, banned)
+	}
+	if _, banned := room.BannedUntil("a", now.Add(3*time.Hour)); banned {
+		t.Error("expected the ban to expire")
+	}
+}
+
+func TestLiftBan(t *testing.T) {
+	now := time.Now()
+	room := makeRoom()
+	room.Ban("a", "owner", now, now.Add(time.Hour))
+	room.Ban("b", "owner", now, now.Add(time.Hour))
+
+	if !room.LiftBan("a") {
+		t.Fatal("expected a's ban to be lifted")
+	}
+	if _, banned := room.BannedUntil("a", now); banned {
+		t.Error("expected a to be let back in")
+	}
+	if bans := room.ActiveBans(now); len(bans) != 1 || bans[0].UserID != "b" {
+		t.Errorf("expected b still banned, got %+v", bans)
+	}
+	if room.LiftBan("a") {
+		t.Error("expected lifting twice to report no ban")
+	}
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"math"
+	"strconv"
+)
+
+const (
+	// MaxConfidence is the highest confidence a vote can carry; 0 means none
+	// was given.
+	MaxConfidence = 5
+	// LowConfidence is the highest confidence that counts as low.
+	LowConfidence = 2
+	// unratedConfidenceWeight weighs votes without a confidence in the
+	// weighted average, as the middle of the scale.
+	unratedConfidenceWeight = 3
+)
+
+// ConfidenceStats sums up the confidence votes carried at the reveal.
+type ConfidenceStats struct {
+	// Votes counts the votes that carried a confidence, and Average is their
+	// mean confidence.
+	Votes   int     `json:"votes" firestore:"Votes"`
+	Average float64 `json:"average" firestore:"Average"`
+	// WeightedAverage is the average of the numeric votes, each weighted by
+	// its confidence; votes without one weigh as a 3.
+	WeightedAverage float64 `json:"weighted_average" firestore:"WeightedAverage"`
+	// LowConfidenceVotes counts the votes at or below LowConfidence, and
+	// LowConfidenceMembers names their voters unless the room is anonymous.
+	LowConfidenceVotes   int      `json:"low_confidence_votes" firestore:"LowConfidenceVotes"`
+	LowConfidenceMembers []string `json:"low_confidence_members,omitempty" firestore:"LowConfidenceMembers"`
+	// NeedsRefinement is set when half or more of the confidence votes are
+	// low.
+	NeedsRefinement bool `json:"needs_refinement" firestore:"NeedsRefinement"`
+}
+
+// SetConfidence records how sure the member at index is of their vote, from
+// 1 to MaxConfidence, or 0 for not saying. A member without a vote has
+// none.
+func (r *Room) SetConfidence(index int, confidence int) {
+	if !r.Members[index].HasVote() {
+		confidence = 0
+	}
+	r.Members[index].Confidence = confidence
+}
+
+// computeConfidenceStats returns nil when no vote carried a confidence.
+func (r *Room) computeConfidenceStats() *ConfidenceStats {
+	stats := ConfidenceStats{}
+	var confidenceSum, weightedSum, weights float64
+	for _, m := range r.Members {
+		if !m.HasVote() {
+			continue
+		}
+		weight := float64(unratedConfidenceWeight)
+		if m.Confidence > 0 {
+			weight = float64(m.Confidence)
+			confidenceSum += weight
+			stats.Votes++
+			if m.Confidence <= LowConfidence {
+				stats.LowConfidenceVotes++
+				stats.LowConfidenceMembers = append(stats.LowConfidenceMembers, m.ID)
+			}
+		}
+		if v, err := strconv.ParseFloat(m.EstimatedValue, 64); err == nil {
+			weightedSum += weight * v
+			weights += weight
+		}
+	}
+	if stats.Votes == 0 {
+		return nil
+	}
+	stats.Average = math.Round(confidenceSum/float64(stats.Votes)*10) / 10
+	if weights > 0 {
+		stats.WeightedAverage = math.Round(weightedSum/weights*10) / 10
+	}
+	stats.NeedsRefinement = stats.LowConfidenceVotes*2 >= stats.Votes
+	return &stats
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevealCards_WeighsVotesByConfidence(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "8"), makeMember("c", "5")}
+	room.SetConfidence(0, 5)
+	room.SetConfidence(1, 1)
+	now := time.Now()
+
+	room.RevealCards(0, now)
+
+	stats := room.ConfidenceStats
+	if stats == nil {
+		t.Fatal("expected confidence stats")
+	}
+	// (5*3 + 1*8 + 3*5) / 9 = 4.2; c gave no confidence and weighs 3.
+	if stats.Votes != 2 || stats.Average != 3 || stats.WeightedAverage != 4.2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.LowConfidenceVotes != 1 || len(stats.LowConfidenceMembers) != 1 || stats.LowConfidenceMembers[0] != "b" {
+		t.Errorf("expected b as the only low-confidence voter, got %+v", stats)
+	}
+	if !stats.NeedsRefinement || !room.TicketEstimation.NeedsRefinement {
+		t.Error("expected one low vote out of two to flag the ticket")
+	}
+
+	room.Restart(now)
+	if room.ConfidenceStats != nil || room.Members[0].Confidence != 0 {
+		t.Error("expected the next round to clear confidence")
+	}
+}
+
+func TestRevealCards_NoConfidenceGivesNoStats(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "5")}
+
+	room.RevealCards(0, time.Now())
+
+	if room.ConfidenceStats != nil {
+		t.Errorf("expected no stats, got %+v", room.ConfidenceStats)
+	}
+}
+
+func TestSetConfidence_WithoutVoteIsDropped(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("a", "")}
+
+	room.SetConfidence(0, 4)
+	if room.Members[0].Confidence != 0 {
+		t.Errorf("expected no confidence without a vote, got %d", room.Members[0].Confidence)
+	}
+
+	room.UpdateEstimatedValue(0, "5", time.Now())
+	room.SetConfidence(0, 4)
+	room.UpdateEstimatedValue(0, "", time.Now())
+	if room.Members[0].Confidence != 0 {
+		t.Error("expected withdrawing the vote to withdraw its confidence")
+	}
+}
+
+func TestViewFor_AnonymousRoomHidesLowConfidenceVoters(t *testing.T) {
+	room := makeAnonymousRoom()
+	room.SetConfidence(0, 1)
+	room.RevealCards(0, time.Now())
+	room.RecordRound(time.Now(), 10)
+
+	view := room.ViewFor("a")
+	if view.ConfidenceStats == nil || view.ConfidenceStats.LowConfidenceVotes != 1 {
+		t.Fatalf("expected the low-confidence count, got %+v", view.ConfidenceStats)
+	}
+	if view.ConfidenceStats.LowConfidenceMembers != nil || view.Rounds[0].ConfidenceStats.LowConfidenceMembers != nil {
+		t.Error("expected no names of low-confidence voters")
+	}
+	if view.Rounds[0].Confidences != nil {
+		t.Errorf("expected the round's confidences dropped, got %v", view.Rounds[0].Confidences)
+	}
+	if room.ConfidenceStats.LowConfidenceMembers == nil {
+		t.Error("expected the stored stats untouched")
+	}
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// How the votes on the active ticket moved since its previous round.
+const (
+	// ConvergenceConverged is set once every vote is the same card.
+	ConvergenceConverged = "converged"
+	// ConvergenceConverging is set when the numeric votes spread less than
+	// in the previous round, or as much with more votes on one card.
+	ConvergenceConverging = "converging"
+	ConvergenceDiverging  = "diverging"
+	ConvergenceSteady     = "steady"
+)
+
+// MaxDelphiRounds bounds DelphiMaxRounds.
+const MaxDelphiRounds = 10
+
+var (
+	ErrCardsNotRevealed = errors.New("the cards are not revealed")
+	// ErrDelphiRoundLimit refuses a re-vote once the ticket had
+	// DelphiMaxRounds rounds; the owner picks the final score instead.
+	ErrDelphiRoundLimit = errors.New("the ticket had its last round")
+)
+
+// DelphiRound sums up one reveal of the active ticket. It carries no votes,
+// only how they fell, so anonymous rooms show it as it is.
+type DelphiRound struct {
+	Round      int            `json:"round" firestore:"Round"`
+	TicketKey  string         `json:"ticket_key" firestore:"TicketKey"`
+	RevealedAt time.Time      `json:"revealed_at" firestore:"RevealedAt"`
+	Result     map[string]int `json:"result" firestore:"Result"`
+	Votes      int            `json:"votes" firestore:"Votes"`
+	// Average and Spread, the highest minus the lowest, are over the numeric
+	// votes. Agreement is the share of the votes on the most voted card.
+	Average   float64 `json:"average" firestore:"Average"`
+	Spread    float64 `json:"spread" firestore:"Spread"`
+	Agreement float64 `json:"agreement" firestore:"Agreement"`
+}
+
+// RecordDelphiRound adds the reveal to the rounds of the active ticket,
+// starting them over when the ticket changed, and updates Convergence. When
+// the ticket has had DelphiMaxRounds rounds without converging, the score
+// is left to the owner: the one stamped on reveal is taken back and
+// AwaitingFacilitator set until ConfirmFinalStoryPoint.
+func (r *Room) RecordDelphiRound(revealedAt time.Time) {
+	key := r.activeTicketKey()
+	if n := len(r.DelphiRounds); n > 0 && r.DelphiRounds[n-1].TicketKey != key {
+		r.DelphiRounds = nil
+	}
+
+	round := DelphiRound{
+		Round:      len(r.DelphiRounds) + 1,
+		TicketKey:  key,
+		RevealedAt: revealedAt,
+		Result:     map[string]int{},
+	}
+	var sum, min, max float64
+	numeric, most := 0, 0
+	for _, m := range r.Members {
+		if m.EstimatedValue == "" {
+			continue
+		}
+		round.Votes++
+		round.Result[m.EstimatedValue]++
+		if n := round.Result[m.EstimatedValue]; n > most {
+			most = n
+		}
+		v, err := strconv.ParseFloat(m.EstimatedValue, 64)
+		if err != nil {
+			continue
+		}
+		if numeric == 0 || v < min {
+			min = v
+		}
+		if numeric == 0 || v > max {
+			max = v
+		}
+		sum += v
+		numeric++
+	}
+	if numeric > 0 {
+		round.Average = math.Round(sum/float64(numeric)*10) / 10
+		round.Spread = max - min
+	}
+	if round.Votes > 0 {
+		round.Agreement = math.Round(float64(most)/float64(round.Votes)*100) / 100
+	}
+
+	r.DelphiRounds = append(r.DelphiRounds, round)
+	r.Convergence = r.convergence()
+	r.AwaitingFacilitator = false
+	if r.DelphiMaxRounds > 0 && round.Round >= r.DelphiMaxRounds && r.Convergence != ConvergenceConverged {
+		r.ConfirmFinalStoryPoint("", revealedAt)
+		r.AwaitingFacilitator = true
+	}
+}
+
+func (r *Room) convergence() string {
+	n := len(r.DelphiRounds)
+	last := r.DelphiRounds[n-1]
+	switch {
+	case last.Votes > 0 && last.Agreement == 1:
+		return ConvergenceConverged
+	case n == 1:
+		return ""
+	}
+	previous := r.DelphiRounds[n-2]
+	switch {
+	case last.Spread < previous.Spread:
+		return ConvergenceConverging
+	case last.Spread > previous.Spread:
+		return ConvergenceDiverging
+	case last.Agreement > previous.Agreement:
+		return ConvergenceConverging
+	case last.Agreement < previous.Agreement:
+		return ConvergenceDiverging
+	}
+	return ConvergenceSteady
+}
+
+// Revote starts the next round on the same ticket after a reveal. The votes
+// are withdrawn, and the rounds so far stay in DelphiRounds.
+func (r *Room) Revote(updatedAt time.Time) error {
+	if r.Status != "REVEALED_CARDS" {
+		return ErrCardsNotRevealed
+	}
+	if r.DelphiMaxRounds > 0 && len(r.DelphiRounds) >= r.DelphiMaxRounds {
+		return ErrDelphiRoundLimit
+	}
+	r.Status = "VOTING"
+	r.UpdatedAt = updatedAt
+	r.Result = map[string]int{}
+	r.FinalStoryPoint = ""
+	r.DimensionStats = nil
+	r.ConfidenceStats = nil
+	r.AwaitingFacilitator = false
+	for i := range r.Members {
+		r.Members[i].EstimatedValue = ""
+		r.Members[i].DimensionValues = nil
+		r.Members[i].Confidence = 0
+	}
+	return nil
+}
+
+// SetDelphiMaxRounds caps the rounds a ticket gets, 0 for no cap. It takes
+// effect from the next reveal.
+func (r *Room) SetDelphiMaxRounds(maxRounds int, updatedAt time.Time) {
+	r.DelphiMaxRounds = maxRounds
+	r.UpdatedAt = updatedAt
+}
+
+func (r *Room) activeTicketKey() string {
+	if r.TicketEstimation == nil {
+		return ""
+	}
+	return ticketKey(r.TicketEstimation)
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func delphiRoom(votes ...string) *Room {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3,5,8,13"
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.TicketQueue = []TicketEstimation{{Name: "T-1"}, {Name: "T-2"}}
+	for i, v := range votes {
+		room.Members = append(room.Members, makeMember(string(rune('a'+i)), v))
+	}
+	room.UpdateResult()
+	return room
+}
+
+func reveal(room *Room, now time.Time) {
+	room.RevealCards(0, now)
+	room.RecordDelphiRound(now)
+	room.RecordRound(now, 10)
+}
+
+func vote(room *Room, votes ...string) {
+	for i, v := range votes {
+		room.UpdateEstimatedValue(i, v, time.Now())
+	}
+	room.UpdateResult()
+}
+
+func TestRevote_KeepsTicketAndTracksConvergence(t *testing.T) {
+	room := delphiRoom("1", "8", "3")
+	now := time.Now()
+	reveal(room, now)
+
+	if err := room.Revote(now); err != nil {
+		t.Fatal(err)
+	}
+	if room.Status != "VOTING" || room.TicketEstimation.Name != "T-1" || room.Members[0].EstimatedValue != "" {
+		t.Errorf("expected a fresh round on T-1, got status %s ticket %+v", room.Status, room.TicketEstimation)
+	}
+
+	vote(room, "3", "5", "3")
+	reveal(room, now)
+	if len(room.DelphiRounds) != 2 || room.DelphiRounds[0].Spread != 7 || room.DelphiRounds[1].Spread != 2 {
+		t.Fatalf("unexpected rounds %+v", room.DelphiRounds)
+	}
+	if room.Convergence != ConvergenceConverging {
+		t.Errorf("expected converging, got %q", room.Convergence)
+	}
+	if room.Rounds[1].DelphiRound != 2 {
+		t.Errorf("expected the history to number the round, got %d", room.Rounds[1].DelphiRound)
+	}
+
+	room.Revote(now)
+	vote(room, "3", "3", "3")
+	reveal(room, now)
+	if room.Convergence != ConvergenceConverged || room.DelphiRounds[2].Agreement != 1 {
+		t.Errorf("expected converged, got %q %+v", room.Convergence, room.DelphiRounds[2])
+	}
+
+	room.Restart(now)
+	if room.DelphiRounds != nil || room.Convergence != "" {
+		t.Error("expected the next ticket to start the rounds over")
+	}
+}
+
+func TestRevote_RefusedBeforeReveal(t *testing.T) {
+	room := delphiRoom("3", "5")
+
+	if err := room.Revote(time.Now()); err != ErrCardsNotRevealed {
+		t.Errorf("expected ErrCardsNotRevealed, got %v", err)
+	}
+}
+
+func TestRecordDelphiRound_LastRoundLeavesScoreToFacilitator(t *testing.T) {
+	room := delphiRoom("1", "8")
+	room.DelphiMaxRounds = 2
+	now := time.Now()
+	reveal(room, now)
+	if room.AwaitingFacilitator || room.FinalStoryPoint == "" {
+		t.Fatal("expected the first round to be scored as usual")
+	}
+
+	room.Revote(now)
+	vote(room, "2", "8")
+	reveal(room, now)
+
+	if !room.AwaitingFacilitator || room.FinalStoryPoint != "" || room.TicketEstimation.FinalScore != "" || room.TicketQueue[0].FinalScore != "" {
+		t.Errorf("expected no stamped score after the last round, got %q %+v", room.FinalStoryPoint, room.TicketEstimation)
+	}
+	if err := room.Revote(now); err != ErrDelphiRoundLimit {
+		t.Errorf("expected ErrDelphiRoundLimit, got %v", err)
+	}
+
+	room.ConfirmFinalStoryPoint("5", now)
+	if room.AwaitingFacilitator || room.TicketEstimation.FinalScore != "5" {
+		t.Error("expected the owner's pick to settle the ticket")
+	}
+}
+
+func TestRecordDelphiRound_NewTicketStartsOver(t *testing.T) {
+	room := delphiRoom("1", "8")
+	now := time.Now()
+	reveal(room, now)
+	room.Revote(now)
+
+	room.SetTicketEstimation(&TicketEstimation{Name: "T-2"}, now)
+	vote(room, "3", "3")
+	reveal(room, now)
+
+	if len(room.DelphiRounds) != 1 || room.DelphiRounds[0].TicketKey != "T-2" {
+		t.Errorf("expected one round of T-2, got %+v", room.DelphiRounds)
+	}
+}
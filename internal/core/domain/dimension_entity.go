@@ -0,0 +1,357 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Score formulas a multi-dimensional room derives story points with.
+const (
+	// FormulaWeightedSum adds up the average of every dimension times its
+	// weight, and takes the card of the room's deck nearest the sum.
+	FormulaWeightedSum = "weighted_sum"
+	// FormulaMatrix looks the consensus card of every dimension up in a table.
+	FormulaMatrix = "matrix"
+)
+
+// MatrixKeySeparator joins the cards of a FormulaMatrix key, one per
+// dimension in the room's order, e.g. "S|high|3".
+const MatrixKeySeparator = "|"
+
+const (
+	maxDimensions      = 5
+	maxDimensionDeck   = 200
+	maxMatrixEntries   = 1000
+	maxMatrixValueSize = 20
+)
+
+var dimensionKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+var (
+	ErrInvalidDimensionVote = errors.New("invalid dimension vote")
+	// ErrDimensionsRequired refuses a single value in a multi-dimensional room.
+	ErrDimensionsRequired = errors.New("this room votes on dimensions")
+)
+
+// Dimension is one aspect of a ticket that a multi-dimensional room
+// estimates, such as complexity or uncertainty, with its own deck.
+type Dimension struct {
+	Key  string `json:"key" firestore:"Key"`
+	Name string `json:"name" firestore:"Name"`
+	Deck string `json:"deck" firestore:"Deck"`
+	// Weight is the dimension's factor under FormulaWeightedSum.
+	Weight float64 `json:"weight,omitempty" firestore:"Weight"`
+}
+
+// ScoreFormula derives a story point from the votes on every dimension.
+type ScoreFormula struct {
+	Kind string `json:"kind" firestore:"Kind"`
+	// Matrix maps a key of one card per dimension, see MatrixKeySeparator,
+	// to a story point. It covers every combination of cards.
+	Matrix map[string]string `json:"matrix,omitempty" firestore:"Matrix"`
+}
+
+// DimensionStats sums up the votes on one dimension at the reveal.
+type DimensionStats struct {
+	Result map[string]int `json:"result" firestore:"Result"`
+	// Votes counts every vote; Average, Min and Max only the numeric ones.
+	Votes   int     `json:"votes" firestore:"Votes"`
+	Average float64 `json:"average" firestore:"Average"`
+	Min     float64 `json:"min" firestore:"Min"`
+	Max     float64 `json:"max" firestore:"Max"`
+	// Consensus is the card of the dimension's deck nearest the average, or
+	// the most voted card when no vote is numeric.
+	Consensus string `json:"consensus" firestore:"Consensus"`
+}
+
+// ValidateDimensions checks a dimension set and its formula before a room
+// takes them. No dimensions, and then no formula, turns the room back to
+// single-value voting.
+func ValidateDimensions(dimensions []Dimension, formula *ScoreFormula) error {
+	if len(dimensions) == 0 {
+		if formula != nil {
+			return errors.New("scoreFormula needs dimensions")
+		}
+		return nil
+	}
+	if len(dimensions) > maxDimensions {
+		return fmt.Errorf("at most %d dimensions", maxDimensions)
+	}
+	if formula == nil {
+		return errors.New("scoreFormula is required with dimensions")
+	}
+
+	seen := map[string]bool{}
+	combinations := 1
+	for _, d := range dimensions {
+		if !dimensionKeyPattern.MatchString(d.Key) {
+			return fmt.Errorf("dimension key %q must be 1-32 lowercase letters, digits or underscores", d.Key)
+		}
+		if seen[d.Key] {
+			return fmt.Errorf("dimension key %q is used twice", d.Key)
+		}
+		seen[d.Key] = true
+		if len(d.Name) == 0 || len(d.Name) > 50 {
+			return fmt.Errorf("dimension %s: name must be 1-50 characters", d.Key)
+		}
+		if len(d.Deck) == 0 || len(d.Deck) > maxDimensionDeck {
+			return fmt.Errorf("dimension %s: deck must be 1-%d characters", d.Key, maxDimensionDeck)
+		}
+		cards := DeckCards(d.Deck)
+		for _, card := range cards {
+			if card == "" || strings.Contains(card, MatrixKeySeparator) {
+				return fmt.Errorf("dimension %s: deck must be a comma-separated list of cards without %q", d.Key, MatrixKeySeparator)
+			}
+		}
+		if math.IsNaN(d.Weight) || d.Weight < 0 || d.Weight > 100 {
+			return fmt.Errorf("dimension %s: weight must be between 0 and 100", d.Key)
+		}
+		combinations *= len(cards)
+	}
+
+	switch formula.Kind {
+	case FormulaWeightedSum:
+		if len(formula.Matrix) > 0 {
+			return errors.New("a weighted_sum formula takes no matrix")
+		}
+		for _, d := range dimensions {
+			if !hasNumericCard(d.Deck) {
+				return fmt.Errorf("dimension %s: weighted_sum needs numeric cards", d.Key)
+			}
+		}
+	case FormulaMatrix:
+		if combinations > maxMatrixEntries {
+			return fmt.Errorf("the dimension decks have %d combinations, a matrix takes at most %d", combinations, maxMatrixEntries)
+		}
+		if len(formula.Matrix) != combinations {
+			return fmt.Errorf("the matrix must map each of the %d combinations of cards", combinations)
+		}
+		for key, value := range formula.Matrix {
+			if !isMatrixKey(dimensions, key) {
+				return fmt.Errorf("matrix key %q is not one card per dimension", key)
+			}
+			if len(value) == 0 || len(value) > maxMatrixValueSize {
+				return fmt.Errorf("matrix value for %q must be 1-%d characters", key, maxMatrixValueSize)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown scoreFormula kind %q", formula.Kind)
+	}
+	return nil
+}
+
+// MultiDimensional reports whether members vote on dimensions rather than
+// with a single card.
+func (r *Room) MultiDimensional() bool {
+	return len(r.Dimensions) > 0
+}
+
+// SetDimensions replaces the room's dimensions and formula, which must have
+// passed ValidateDimensions. Every vote of the round is withdrawn; the IDs
+// of the members who had one are returned.
+func (r *Room) SetDimensions(dimensions []Dimension, formula *ScoreFormula, updatedAt time.Time) []string {
+	var withdrawn []string
+	for i, m := range r.Members {
+		if m.HasVote() {
+			withdrawn = append(withdrawn, m.ID)
+		}
+		r.Members[i].EstimatedValue = ""
+		r.Members[i].DimensionValues = nil
+		r.Members[i].Confidence = 0
+	}
+	r.Dimensions = dimensions
+	r.ScoreFormula = formula
+	if len(dimensions) == 0 {
+		r.ScoreFormula = nil
+	}
+	r.DimensionStats = nil
+	r.UpdatedAt = updatedAt
+	r.UpdateResult()
+	return withdrawn
+}
+
+// VoteDimensions records the member's cards for some of the dimensions; an
+// empty card withdraws that vote. Once every dimension has a card, the
+// member's EstimatedValue is the story point the formula derives from them
+// alone, or "?" when it derives none.
+func (r *Room) VoteDimensions(index int, values map[string]string, updatedAt time.Time) error {
+	for key, card := range values {
+		d, ok := r.dimension(key)
+		if !ok {
+			return fmt.Errorf("%w: no dimension %q", ErrInvalidDimensionVote, key)
+		}
+		if card != "" && !containsString(DeckCards(d.Deck), card) {
+			return fmt.Errorf("%w: %q is not a card of %s", ErrInvalidDimensionVote, card, key)
+		}
+	}
+
+	member := &r.Members[index]
+	votes := make(map[string]string, len(r.Dimensions))
+	for key, card := range member.DimensionValues {
+		votes[key] = card
+	}
+	for key, card := range values {
+		if card == "" {
+			delete(votes, key)
+		} else {
+			votes[key] = card
+		}
+	}
+	member.DimensionValues = votes
+	member.EstimatedValue = ""
+	if len(votes) == len(r.Dimensions) {
+		member.EstimatedValue = r.memberScore(votes)
+	}
+	if len(votes) == 0 {
+		member.DimensionValues = nil
+		member.Confidence = 0
+	}
+	member.LastActiveAt = updatedAt
+	r.UpdatedAt = updatedAt
+	return nil
+}
+
+func (r *Room) dimension(key string) (Dimension, bool) {
+	for _, d := range r.Dimensions {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Dimension{}, false
+}
+
+// memberScore derives one member's story point from their own cards.
+func (r *Room) memberScore(votes map[string]string) string {
+	stats := map[string]DimensionStats{}
+	for _, d := range r.Dimensions {
+		stats[d.Key] = summarize(d.Deck, []string{votes[d.Key]})
+	}
+	if score, _, ok := r.deriveScore(stats); ok {
+		return score
+	}
+	return "?"
+}
+
+// computeDimensionStats sums up the votes on every dimension.
+func (r *Room) computeDimensionStats() map[string]DimensionStats {
+	stats := make(map[string]DimensionStats, len(r.Dimensions))
+	for _, d := range r.Dimensions {
+		var cards []string
+		for _, m := range r.Members {
+			if card := m.DimensionValues[d.Key]; card != "" {
+				cards = append(cards, card)
+			}
+		}
+		stats[d.Key] = summarize(d.Deck, cards)
+	}
+	return stats
+}
+
+// deriveScore applies the formula to per-dimension stats. It returns the
+// story point, the figure it was rounded from, and false when the votes do
+// not allow one.
+func (r *Room) deriveScore(stats map[string]DimensionStats) (string, float64, bool) {
+	if r.ScoreFormula == nil {
+		return "", 0, false
+	}
+	switch r.ScoreFormula.Kind {
+	case FormulaWeightedSum:
+		var sum float64
+		for _, d := range r.Dimensions {
+			s := stats[d.Key]
+			if s.Consensus == "" || !hasNumericVote(s) {
+				return "", 0, false
+			}
+			sum += d.Weight * s.Average
+		}
+		sum = math.Round(sum*10) / 10
+		score := nearestDeckOption(r.DeskConfig, sum)
+		return score, sum, score != ""
+	case FormulaMatrix:
+		cards := make([]string, len(r.Dimensions))
+		for i, d := range r.Dimensions {
+			cards[i] = stats[d.Key].Consensus
+		}
+		score, ok := r.ScoreFormula.Matrix[strings.Join(cards, MatrixKeySeparator)]
+		if !ok {
+			return "", 0, false
+		}
+		value, _ := strconv.ParseFloat(score, 64)
+		return score, value, true
+	}
+	return "", 0, false
+}
+
+func summarize(deck string, cards []string) DimensionStats {
+	stats := DimensionStats{Result: map[string]int{}}
+	var sum float64
+	numeric := 0
+	for _, card := range cards {
+		if card == "" {
+			continue
+		}
+		stats.Result[card]++
+		stats.Votes++
+		v, err := strconv.ParseFloat(card, 64)
+		if err != nil {
+			continue
+		}
+		if numeric == 0 || v < stats.Min {
+			stats.Min = v
+		}
+		if numeric == 0 || v > stats.Max {
+			stats.Max = v
+		}
+		sum += v
+		numeric++
+	}
+	if numeric > 0 {
+		stats.Average = math.Round(sum/float64(numeric)*10) / 10
+		stats.Consensus = nearestDeckOption(deck, stats.Average)
+		return stats
+	}
+	// Ties go to the card later in the deck, the more cautious one on
+	// decks ordered from small to large.
+	for _, card := range DeckCards(deck) {
+		if n := stats.Result[card]; n > 0 && n >= stats.Result[stats.Consensus] {
+			stats.Consensus = card
+		}
+	}
+	return stats
+}
+
+func hasNumericVote(stats DimensionStats) bool {
+	for card := range stats.Result {
+		if _, err := strconv.ParseFloat(card, 64); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNumericCard(deck string) bool {
+	for _, card := range DeckCards(deck) {
+		if _, err := strconv.ParseFloat(card, 64); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isMatrixKey(dimensions []Dimension, key string) bool {
+	cards := strings.Split(key, MatrixKeySeparator)
+	if len(cards) != len(dimensions) {
+		return false
+	}
+	for i, d := range dimensions {
+		if !containsString(DeckCards(d.Deck), cards[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func weightedRoom() *Room {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3,5,8,13,?"
+	room.Members = []Member{makeMember("a", ""), makeMember("b", "")}
+	room.SetDimensions([]Dimension{
+		{Key: "complexity", Name: "Complexity", Deck: "1,2,3,5,8", Weight: 1},
+		{Key: "uncertainty", Name: "Uncertainty", Deck: "1,2,3,?", Weight: 2},
+	}, &ScoreFormula{Kind: FormulaWeightedSum}, time.Now())
+	return room
+}
+
+func TestValidateDimensions(t *testing.T) {
+	sizes := []Dimension{{Key: "size", Name: "Size", Deck: "S,L"}, {Key: "risk", Name: "Risk", Deck: "low,high"}}
+	full := map[string]string{"S|low": "2", "S|high": "3", "L|low": "5", "L|high": "8"}
+
+	if err := ValidateDimensions(sizes, &ScoreFormula{Kind: FormulaMatrix, Matrix: full}); err != nil {
+		t.Errorf("expected a full matrix to be accepted, got %v", err)
+	}
+	if err := ValidateDimensions(nil, nil); err != nil {
+		t.Errorf("expected no dimensions to be accepted, got %v", err)
+	}
+
+	delete(full, "L|high")
+	for name, tc := range map[string]struct {
+		dimensions []Dimension
+		formula    *ScoreFormula
+	}{
+		"incomplete matrix":       {sizes, &ScoreFormula{Kind: FormulaMatrix, Matrix: full}},
+		"no formula":              {sizes, nil},
+		"formula, no dimensions":  {nil, &ScoreFormula{Kind: FormulaWeightedSum}},
+		"weighted sum, no number": {sizes, &ScoreFormula{Kind: FormulaWeightedSum}},
+		"bad key":                 {[]Dimension{{Key: "Size!", Name: "Size", Deck: "1,2"}}, &ScoreFormula{Kind: FormulaWeightedSum}},
+		"unknown kind":            {sizes, &ScoreFormula{Kind: "median"}},
+	} {
+		if err := ValidateDimensions(tc.dimensions, tc.formula); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestVoteDimensions_DerivesMemberScoreOnceEveryDimensionHasACard(t *testing.T) {
+	room := weightedRoom()
+	now := time.Now()
+
+	if err := room.VoteDimensions(0, map[string]string{"complexity": "3"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if room.Members[0].EstimatedValue != "" {
+		t.Errorf("expected no score before every dimension is voted, got %q", room.Members[0].EstimatedValue)
+	}
+	if err := room.VoteDimensions(0, map[string]string{"uncertainty": "1"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := room.Members[0].EstimatedValue; got != "5" {
+		t.Errorf("expected 3*1 + 1*2 = 5, got %q", got)
+	}
+	if err := room.VoteDimensions(0, map[string]string{"uncertainty": "?"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := room.Members[0].EstimatedValue; got != "?" {
+		t.Errorf("expected ? when the formula derives nothing, got %q", got)
+	}
+
+	for _, values := range []map[string]string{{"effort": "3"}, {"complexity": "4"}} {
+		if err := room.VoteDimensions(0, values, now); !errors.Is(err, ErrInvalidDimensionVote) {
+			t.Errorf("%v: expected ErrInvalidDimensionVote, got %v", values, err)
+		}
+	}
+}
+
+func TestRevealCards_AppliesWeightedSumToDimensionAverages(t *testing.T) {
+	room := weightedRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	now := time.Now()
+	room.VoteDimensions(0, map[string]string{"complexity": "3", "uncertainty": "1"}, now)
+	room.VoteDimensions(1, map[string]string{"complexity": "5", "uncertainty": "2"}, now)
+
+	room.RevealCards(0, now)
+
+	complexity := room.DimensionStats["complexity"]
+	if complexity.Average != 4 || complexity.Min != 3 || complexity.Max != 5 || complexity.Votes != 2 {
+		t.Errorf("unexpected complexity stats %+v", complexity)
+	}
+	// 4*1 + 1.5*2 = 7, nearest card 8.
+	if room.TicketEstimation.FinalScore != "8" || room.TicketEstimation.AvgScore != 7 {
+		t.Errorf("expected final 8 from 7, got %+v", room.TicketEstimation)
+	}
+
+	room.Restart(now)
+	if room.DimensionStats != nil || room.Members[0].DimensionValues != nil {
+		t.Error("expected the next round to clear the dimension votes")
+	}
+}
+
+func TestRevealCards_LooksConsensusUpInMatrix(t *testing.T) {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3,5,8"
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.Members = []Member{makeMember("a", ""), makeMember("b", ""), makeMember("c", "")}
+	room.SetDimensions([]Dimension{
+		{Key: "size", Name: "Size", Deck: "S,L"},
+		{Key: "risk", Name: "Risk", Deck: "low,high"},
+	}, &ScoreFormula{Kind: FormulaMatrix, Matrix: map[string]string{"S|low": "2", "S|high": "3", "L|low": "5", "L|high": "8"}}, time.Now())
+
+	now := time.Now()
+	room.VoteDimensions(0, map[string]string{"size": "S", "risk": "low"}, now)
+	room.VoteDimensions(1, map[string]string{"size": "L", "risk": "high"}, now)
+	room.VoteDimensions(2, map[string]string{"size": "L", "risk": "low"}, now)
+	if room.Members[1].EstimatedValue != "8" {
+		t.Errorf("expected b's own score from the matrix, got %q", room.Members[1].EstimatedValue)
+	}
+
+	room.RevealCards(0, now)
+	if room.DimensionStats["size"].Consensus != "L" || room.DimensionStats["risk"].Consensus != "low" {
+		t.Errorf("unexpected consensus %+v", room.DimensionStats)
+	}
+	if room.TicketEstimation.FinalScore != "5" {
+		t.Errorf("expected L|low = 5, got %q", room.TicketEstimation.FinalScore)
+	}
+}
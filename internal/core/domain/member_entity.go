@@ -8,6 +8,14 @@ type Member struct {
 	Picture        string    `json:"picture"`
 	LastActiveAt   time.Time `json:"last_active_at"`
 	EstimatedValue string    `json:"estimated_value"`
+	// DimensionValues are the member's cards per dimension key in a
+	// multi-dimensional room; see Room.VoteDimensions.
+	DimensionValues map[string]string `json:"dimension_values,omitempty" firestore:"DimensionValues"`
+	// Confidence is how sure the member is of their vote, 1 to
+	// MaxConfidence, or 0 when they did not say.
+	Confidence int `json:"confidence,omitempty" firestore:"Confidence"`
+	// HasVoted is derived for clients by Room.View and never stored.
+	HasVoted bool `json:"has_voted" firestore:"-"`
 }
 
 func NewMember(id, name, picture string, lastActiveAt time.Time) *Member {
@@ -20,6 +28,12 @@ func NewMember(id, name, picture string, lastActiveAt time.Time) *Member {
 	}
 }
 
+// HasVote reports whether the member has voted this round, with a card or
+// on any dimension.
+func (m *Member) HasVote() bool {
+	return m.EstimatedValue != "" || len(m.DimensionValues) > 0
+}
+
 func (m *Member) SetEstimatedValue(value string) {
 	m.EstimatedValue = value
 }
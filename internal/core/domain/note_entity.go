@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// MaxNoteLength bounds a note's text, and MaxRoomNotes the notes a room
+// keeps, dropping the oldest first.
+const (
+	MaxNoteLength = 1000
+	MaxRoomNotes  = 200
+)
+
+// TicketNote is a remark on a ticket made during its discussion, such as a
+// decision or why it was split. Rounds carry the notes on their ticket.
+type TicketNote struct {
+	ID         string    `json:"id" firestore:"ID"`
+	TicketKey  string    `json:"ticket_key" firestore:"TicketKey"`
+	AuthorID   string    `json:"author_id" firestore:"AuthorID"`
+	AuthorName string    `json:"author_name" firestore:"AuthorName"`
+	Text       string    `json:"text" firestore:"Text"`
+	CreatedAt  time.Time `json:"created_at" firestore:"CreatedAt"`
+}
+
+// AddNote adds a note by the member at index on the active ticket, and to
+// the latest round of the ticket in the history, if any.
+func (r *Room) AddNote(id string, index int, text string, createdAt time.Time) (TicketNote, error) {
+	if r.TicketEstimation == nil {
+		return TicketNote{}, ErrNoActiveTicket
+	}
+	note := TicketNote{
+		ID:         id,
+		TicketKey:  ticketKey(r.TicketEstimation),
+		AuthorID:   r.Members[index].ID,
+		AuthorName: r.Members[index].Name,
+		Text:       strings.TrimSpace(stripControl(text, true)),
+		CreatedAt:  createdAt,
+	}
+	r.Notes = append(r.Notes, note)
+	if over := len(r.Notes) - MaxRoomNotes; over > 0 {
+		r.Notes = append(r.Notes[:0:0], r.Notes[over:]...)
+	}
+	for i := len(r.Rounds) - 1; i >= 0; i-- {
+		if t := r.Rounds[i].Ticket; t != nil && ticketKey(t) == note.TicketKey {
+			r.Rounds[i].Notes = r.notesOn(note.TicketKey)
+			break
+		}
+	}
+	r.UpdatedAt = createdAt
+	return note, nil
+}
+
+// FillRoundNotes gives every round in the history the notes on its ticket
+// made before the ticket's next reveal, as a room loaded from storage has
+// none on its rounds.
+func (r *Room) FillRoundNotes() {
+	for i := range r.Rounds {
+		r.Rounds[i].Notes = nil
+		t := r.Rounds[i].Ticket
+		if t == nil {
+			continue
+		}
+		key := ticketKey(t)
+		var until time.Time
+		for _, later := range r.Rounds[i+1:] {
+			if later.Ticket != nil && ticketKey(later.Ticket) == key {
+				until = later.RevealedAt
+				break
+			}
+		}
+		for _, n := range r.notesOn(key) {
+			if until.IsZero() || n.CreatedAt.Before(until) {
+				r.Rounds[i].Notes = append(r.Rounds[i].Notes, n)
+			}
+		}
+	}
+}
+
+// notesOn returns the notes on the ticket with the given key, oldest first.
+func (r *Room) notesOn(key string) []TicketNote {
+	var notes []TicketNote
+	for _, n := range r.Notes {
+		if n.TicketKey == key {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
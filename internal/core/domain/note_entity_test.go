@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddNote_KeptWithTheTicketsRounds(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.TicketQueue = []TicketEstimation{{Name: "T-1"}, {Name: "T-2"}}
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "5")}
+	now := time.Now()
+
+	if _, err := room.AddNote("n1", 0, "blocked by API", now); err != nil {
+		t.Fatal(err)
+	}
+	room.RevealCards(0, now)
+	room.RecordRound(now, 10)
+	if len(room.Rounds[0].Notes) != 1 || room.Rounds[0].Notes[0].AuthorID != "a" {
+		t.Fatalf("expected the round to carry the note, got %+v", room.Rounds[0].Notes)
+	}
+
+	note, _ := room.AddNote("n2", 1, "  split into two stories\x00 ", now)
+	if note.Text != "split into two stories" || note.TicketKey != "T-1" {
+		t.Errorf("unexpected note %+v", note)
+	}
+	if len(room.Rounds[0].Notes) != 2 {
+		t.Error("expected a note after the reveal to join the ticket's latest round")
+	}
+
+	room.SetTicketEstimation(&TicketEstimation{Name: "T-2"}, now)
+	room.AddNote("n3", 0, "needs design", now)
+	if len(room.Rounds[0].Notes) != 2 || len(room.Notes) != 3 {
+		t.Error("expected a note on T-2 to stay off T-1's round")
+	}
+}
+
+func TestAddNote_RequiresActiveTicket(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("a", "")}
+
+	if _, err := room.AddNote("n1", 0, "note", time.Now()); err != ErrNoActiveTicket {
+		t.Errorf("expected ErrNoActiveTicket, got %v", err)
+	}
+}
+
+func TestAddNote_KeepsLatestNotes(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.Members = []Member{makeMember("a", "")}
+
+	for i := 0; i < MaxRoomNotes+5; i++ {
+		room.AddNote(string(rune('A'+i%26)), 0, "note", time.Now())
+	}
+	if len(room.Notes) != MaxRoomNotes {
+		t.Errorf("expected %d notes, got %d", MaxRoomNotes, len(room.Notes))
+	}
+}
+
+func TestFillRoundNotes_SplitsNotesAtTheNextReveal(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.Members = []Member{makeMember("a", "3")}
+	start := time.Now()
+
+	room.AddNote("n1", 0, "before the first reveal", start)
+	room.RecordRound(start.Add(time.Minute), 10)
+	room.AddNote("n2", 0, "between the reveals", start.Add(2*time.Minute))
+	room.RecordRound(start.Add(3*time.Minute), 10)
+	room.AddNote("n3", 0, "after the last reveal", start.Add(4*time.Minute))
+
+	for i := range room.Rounds {
+		room.Rounds[i].Notes = nil
+	}
+	room.FillRoundNotes()
+
+	if len(room.Rounds[0].Notes) != 2 || room.Rounds[0].Notes[1].ID != "n2" {
+		t.Errorf("expected the first round to end at the second reveal, got %+v", room.Rounds[0].Notes)
+	}
+	if len(room.Rounds[1].Notes) != 3 {
+		t.Errorf("expected the latest round to carry every note, got %+v", room.Rounds[1].Notes)
+	}
+}
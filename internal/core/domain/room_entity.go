@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"math"
 	"strconv"
 	"strings"
@@ -18,6 +19,17 @@ type TicketEstimation struct {
 	StoryPointsField string  `json:"storyPointsField" firestore:"storyPointsField"`
 	AvgScore         float64 `json:"avgScore,omitempty" firestore:"avgScore"`
 	FinalScore       string  `json:"finalScore,omitempty" firestore:"finalScore"`
+	// NeedsRefinement is stamped on reveal when too many votes had low
+	// confidence; see ConfidenceStats.
+	NeedsRefinement bool `json:"needsRefinement,omitempty" firestore:"needsRefinement"`
+	// NeedsDiscussion is stamped when an async session reveals the ticket
+	// with votes too far apart to settle without a live discussion.
+	NeedsDiscussion bool `json:"needsDiscussion,omitempty" firestore:"needsDiscussion"`
+	// Description is Markdown. See TicketDetails.
+	Description        string   `json:"description,omitempty" firestore:"description,omitempty"`
+	AcceptanceCriteria []string `json:"acceptanceCriteria,omitempty" firestore:"acceptanceCriteria,omitempty"`
+	Labels             []string `json:"labels,omitempty" firestore:"labels,omitempty"`
+	Links              []string `json:"links,omitempty" firestore:"links,omitempty"`
 }
 
 type Room struct {
@@ -33,8 +45,64 @@ type Room struct {
 	TicketEstimation    *TicketEstimation  `json:"ticket_estimation" firestore:"TicketEstimation"`
 	TicketQueue         []TicketEstimation `json:"ticket_queue" firestore:"TicketQueue"`
 	FinalStoryPoint     string             `json:"final_story_point" firestore:"FinalStoryPoint"`
+	// Version increases by one with every persisted change so clients applying
+	// delta events can detect a gap and ask for a fresh snapshot.
+	Version int64 `json:"version" firestore:"Version"`
+	// OwnerID is the user who created the room. Rooms created before owners
+	// were recorded have none; see Owner.
+	OwnerID string `json:"owner_id" firestore:"OwnerID"`
+	// Private rooms can only be seen by their members and owner, and joined
+	// with the passcode or an invite. PasscodeHash is a bcrypt hash and never
+	// leaves the server.
+	Private      bool   `json:"private" firestore:"Private"`
+	PasscodeHash string `json:"-" firestore:"PasscodeHash"`
+	// Bans lists the users kept out of the room, expired ones included until
+	// the list is next written.
+	Bans []Ban `json:"-" firestore:"Bans"`
+	// AnonymousVoting hides who voted what from everyone; see View.
+	// RecordVotes still keeps each member's vote in the history of an
+	// anonymous room, for its owner.
+	AnonymousVoting bool `json:"anonymous_voting" firestore:"AnonymousVoting"`
+	RecordVotes     bool `json:"record_votes" firestore:"RecordVotes"`
+	// Rounds is the history of revealed rounds, oldest first.
+	Rounds []Round `json:"rounds" firestore:"Rounds"`
+	// Dimensions, when set, are voted on separately and ScoreFormula turns
+	// their votes into story points; see dimension_entity.go. DimensionStats
+	// is set from the reveal until the next round.
+	Dimensions     []Dimension               `json:"dimensions" firestore:"Dimensions"`
+	ScoreFormula   *ScoreFormula             `json:"score_formula" firestore:"ScoreFormula"`
+	DimensionStats map[string]DimensionStats `json:"dimension_stats" firestore:"DimensionStats"`
+	// ConfidenceStats is set from the reveal until the next round when any
+	// vote carried a confidence.
+	ConfidenceStats *ConfidenceStats `json:"confidence_stats" firestore:"ConfidenceStats"`
+	// DelphiRounds sums up the reveals of the active ticket, oldest first,
+	// for Wideband Delphi re-votes; see delphi_entity.go. DelphiMaxRounds
+	// caps them, 0 for no cap.
+	DelphiRounds        []DelphiRound `json:"delphi_rounds" firestore:"DelphiRounds"`
+	DelphiMaxRounds     int           `json:"delphi_max_rounds" firestore:"DelphiMaxRounds"`
+	Convergence         string        `json:"convergence" firestore:"Convergence"`
+	AwaitingFacilitator bool          `json:"awaiting_facilitator" firestore:"AwaitingFacilitator"`
+	// Async is the open async estimation session, if any; see
+	// async_entity.go.
+	Async *AsyncSession `json:"async" firestore:"Async"`
+	// Notes are the notes on the room's tickets, oldest first; see
+	// note_entity.go.
+	Notes []TicketNote `json:"notes" firestore:"Notes"`
 }
 
+// Vote policies for ChangeDeck, deciding what happens to votes that are not
+// a card of the new deck.
+const (
+	// DeckVotesClearInvalid withdraws only the votes not on the new deck.
+	DeckVotesClearInvalid = "clear_invalid"
+	// DeckVotesClearAll withdraws every vote of the round.
+	DeckVotesClearAll = "clear_all"
+	// DeckVotesReject refuses the change while any vote is not on the new deck.
+	DeckVotesReject = "reject"
+)
+
+var ErrVotesNotOnDeck = errors.New("some votes are not on the new deck")
+
 func NewRoom(name, roomId, deskConfig string) *Room {
 	now := time.Now()
 	return &Room{
@@ -50,19 +118,96 @@ func NewRoom(name, roomId, deskConfig string) *Room {
 	}
 }
 
-func (r *Room) JoinRoom(member *Member, updatedAt time.Time) {
+// BumpVersion records one state change. Call it once per persisted operation.
+func (r *Room) BumpVersion() {
+	r.Version++
+}
+
+// Owner returns the ID of the user allowed to manage the room. Rooms without
+// a recorded owner are owned by the first user who joined them.
+func (r *Room) Owner() string {
+	if r.OwnerID != "" {
+		return r.OwnerID
+	}
+	if len(r.EverJoinedMemberIDs) > 0 {
+		return r.EverJoinedMemberIDs[0]
+	}
+	return ""
+}
+
+func (r *Room) IsOwner(uid string) bool {
+	return uid != "" && r.Owner() == uid
+}
+
+// CanView reports whether uid may see the room and its events without
+// joining first.
+func (r *Room) CanView(uid string) bool {
+	return !r.Private || r.CheckMember(uid) || r.IsOwner(uid)
+}
+
+// SetPasscode makes the room private behind passcodeHash, or public again
+// when it is empty.
+func (r *Room) SetPasscode(passcodeHash string, updatedAt time.Time) {
+	r.PasscodeHash = passcodeHash
+	r.Private = passcodeHash != ""
 	r.UpdatedAt = updatedAt
-	r.Members = append(r.Members, *member)
-	r.MemberIDs = append(r.MemberIDs, member.ID)
+}
 
-	alreadyRecorded := false
-	for _, id := range r.EverJoinedMemberIDs {
-		if id == member.ID {
-			alreadyRecorded = true
-			break
+func (r *Room) Rename(name string, updatedAt time.Time) {
+	r.Name = name
+	r.UpdatedAt = updatedAt
+}
+
+// ChangeDeck swaps the deck, handling votes that are no longer cards as
+// policy says. It returns the IDs of members whose vote was withdrawn, or
+// ErrVotesNotOnDeck under DeckVotesReject.
+func (r *Room) ChangeDeck(deskConfig, policy string, updatedAt time.Time) ([]string, error) {
+	onDeck := map[string]bool{}
+	for _, card := range DeckCards(deskConfig) {
+		onDeck[card] = true
+	}
+
+	var withdrawn []string
+	for i, m := range r.Members {
+		if m.EstimatedValue == "" {
+			continue
+		}
+		if policy != DeckVotesClearAll && onDeck[m.EstimatedValue] {
+			continue
+		}
+		if policy == DeckVotesReject {
+			return nil, ErrVotesNotOnDeck
 		}
+		r.Members[i].EstimatedValue = ""
+		r.Members[i].DimensionValues = nil
+		r.Members[i].Confidence = 0
+		withdrawn = append(withdrawn, m.ID)
 	}
-	if !alreadyRecorded {
+
+	r.DeskConfig = deskConfig
+	r.UpdatedAt = updatedAt
+	if len(withdrawn) > 0 {
+		r.UpdateResult()
+	}
+	return withdrawn, nil
+}
+
+// JoinRoom adds member to the room. A member who is already in it keeps
+// their place and vote; only the name, picture and activity are updated.
+func (r *Room) JoinRoom(member *Member, updatedAt time.Time) {
+	r.UpdatedAt = updatedAt
+	if i := r.memberIndex(member.ID); i >= 0 {
+		r.Members[i].Name = member.Name
+		r.Members[i].Picture = member.Picture
+		r.Members[i].LastActiveAt = member.LastActiveAt
+	} else {
+		r.Members = append(r.Members, *member)
+	}
+	if !containsString(r.MemberIDs, member.ID) {
+		r.MemberIDs = append(r.MemberIDs, member.ID)
+	}
+
+	if !containsString(r.EverJoinedMemberIDs, member.ID) {
 		r.EverJoinedMemberIDs = append(r.EverJoinedMemberIDs, member.ID)
 	}
 }
@@ -93,6 +238,33 @@ func (r *Room) KickMember(memberID string, updatedAt time.Time) bool {
 	return true
 }
 
+// Leave takes the member out of the room, withdrawing their vote. They stay
+// in EverJoinedMemberIDs, so the room is still in their recent rooms.
+func (r *Room) Leave(memberID string, updatedAt time.Time) bool {
+	if !r.KickMember(memberID, updatedAt) {
+		return false
+	}
+	r.UpdateResult()
+	return true
+}
+
+// UpdateProfile changes the name and picture of the member at index.
+func (r *Room) UpdateProfile(index int, name, picture string, updatedAt time.Time) {
+	r.Members[index].Name = name
+	r.Members[index].Picture = picture
+	r.Members[index].LastActiveAt = updatedAt
+	r.UpdatedAt = updatedAt
+}
+
+func (r *Room) memberIndex(id string) int {
+	for i, member := range r.Members {
+		if member.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 func (r *Room) CheckMember(id string) bool {
 	for _, member := range r.Members {
 		if member.ID == id {
@@ -105,6 +277,9 @@ func (r *Room) CheckMember(id string) bool {
 
 func (r *Room) UpdateEstimatedValue(index int, value string, updatedAt time.Time) {
 	r.Members[index].EstimatedValue = value
+	if value == "" {
+		r.Members[index].Confidence = 0
+	}
 	r.Members[index].LastActiveAt = updatedAt
 	r.UpdatedAt = updatedAt
 }
@@ -122,6 +297,10 @@ func (r *Room) RevealCards(actorIndex int, updatedAt time.Time) {
 	r.Status = "REVEALED_CARDS"
 	r.UpdatedAt = updatedAt
 	r.Members[actorIndex].LastActiveAt = updatedAt
+	if r.MultiDimensional() {
+		r.DimensionStats = r.computeDimensionStats()
+	}
+	r.ConfidenceStats = r.computeConfidenceStats()
 	r.stampTicketScoresOnReveal()
 }
 
@@ -131,8 +310,13 @@ func (r *Room) stampTicketScoresOnReveal() {
 	}
 	avg := r.computeAvgFromVotes()
 	autoFinal := nearestDeckOption(r.DeskConfig, avg)
+	if r.MultiDimensional() {
+		autoFinal, avg, _ = r.deriveScore(r.DimensionStats)
+	}
+	needsRefinement := r.ConfidenceStats != nil && r.ConfidenceStats.NeedsRefinement
 
 	r.TicketEstimation.AvgScore = avg
+	r.TicketEstimation.NeedsRefinement = needsRefinement
 	if autoFinal != "" {
 		r.TicketEstimation.FinalScore = autoFinal
 		r.FinalStoryPoint = autoFinal
@@ -149,6 +333,7 @@ func (r *Room) stampTicketScoresOnReveal() {
 		}
 		if key == estKey {
 			r.TicketQueue[i].AvgScore = avg
+			r.TicketQueue[i].NeedsRefinement = needsRefinement
 			if autoFinal != "" {
 				r.TicketQueue[i].FinalScore = autoFinal
 			}
@@ -160,10 +345,14 @@ func (r *Room) stampTicketScoresOnReveal() {
 func (r *Room) ConfirmFinalStoryPoint(value string, updatedAt time.Time) {
 	r.FinalStoryPoint = value
 	r.UpdatedAt = updatedAt
+	r.AwaitingFacilitator = false
 	if r.TicketEstimation == nil {
 		return
 	}
 	avg := r.computeAvgFromVotes()
+	if r.MultiDimensional() {
+		_, avg, _ = r.deriveScore(r.DimensionStats)
+	}
 	r.TicketEstimation.FinalScore = value
 	r.TicketEstimation.AvgScore = avg
 
@@ -200,12 +389,19 @@ func (r *Room) computeAvgFromVotes() float64 {
 	return math.Round((sum/float64(count))*10) / 10
 }
 
+// DeckCards splits a deck config into its cards.
+func DeckCards(deskConfig string) []string {
+	cards := strings.Split(deskConfig, ",")
+	for i := range cards {
+		cards[i] = strings.TrimSpace(cards[i])
+	}
+	return cards
+}
+
 func nearestDeckOption(deskConfig string, avg float64) string {
-	opts := strings.Split(deskConfig, ",")
 	nearest := ""
 	minDist := math.MaxFloat64
-	for _, opt := range opts {
-		opt = strings.TrimSpace(opt)
+	for _, opt := range DeckCards(deskConfig) {
 		v, err := strconv.ParseFloat(opt, 64)
 		if err != nil {
 			continue
@@ -229,9 +425,16 @@ func (r *Room) Restart(updatedAt time.Time) {
 	r.UpdatedAt = updatedAt
 	r.Result = map[string]int{}
 	r.FinalStoryPoint = ""
+	r.DimensionStats = nil
+	r.ConfidenceStats = nil
+	r.DelphiRounds = nil
+	r.Convergence = ""
+	r.AwaitingFacilitator = false
 
 	for i := range r.Members {
 		r.Members[i].EstimatedValue = ""
+		r.Members[i].DimensionValues = nil
+		r.Members[i].Confidence = 0
 	}
 
 	// Auto-select the first unvoted ticket from the queue
@@ -292,6 +495,110 @@ func (r *Room) SetTicketQueue(queue []TicketEstimation, updatedAt time.Time) {
 	r.UpdatedAt = updatedAt
 }
 
+// RepairMemberIndexes rebuilds MemberIDs from Members (dropping duplicate
+// member entries, last one wins) and makes sure every current member is in
+// EverJoinedMemberIDs. Returns true if anything changed.
+func (r *Room) RepairMemberIndexes() bool {
+	changed := false
+
+	lastIndex := map[string]int{}
+	for i, m := range r.Members {
+		lastIndex[m.ID] = i
+	}
+	members := make([]Member, 0, len(lastIndex))
+	for i, m := range r.Members {
+		if lastIndex[m.ID] == i {
+			members = append(members, m)
+		}
+	}
+	if len(members) != len(r.Members) {
+		changed = true
+	}
+	r.Members = members
+
+	memberIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		memberIDs = append(memberIDs, m.ID)
+	}
+	if !equalStrings(memberIDs, r.MemberIDs) {
+		changed = true
+	}
+	r.MemberIDs = memberIDs
+
+	everJoined := map[string]bool{}
+	dedupedEverJoined := make([]string, 0, len(r.EverJoinedMemberIDs))
+	for _, id := range r.EverJoinedMemberIDs {
+		if everJoined[id] {
+			changed = true
+			continue
+		}
+		everJoined[id] = true
+		dedupedEverJoined = append(dedupedEverJoined, id)
+	}
+	for _, id := range memberIDs {
+		if !everJoined[id] {
+			everJoined[id] = true
+			dedupedEverJoined = append(dedupedEverJoined, id)
+			changed = true
+		}
+	}
+	r.EverJoinedMemberIDs = dedupedEverJoined
+
+	return changed
+}
+
+// Backfill sets defaults for fields that documents written by older versions
+// of the service may be missing. Returns true if anything changed.
+func (r *Room) Backfill() bool {
+	changed := false
+	if r.Members == nil {
+		r.Members = []Member{}
+		changed = true
+	}
+	if r.MemberIDs == nil {
+		r.MemberIDs = []string{}
+		changed = true
+	}
+	if r.EverJoinedMemberIDs == nil {
+		r.EverJoinedMemberIDs = []string{}
+		changed = true
+	}
+	if r.Result == nil {
+		r.Result = map[string]int{}
+		changed = true
+	}
+	if r.Status == "" {
+		r.Status = "VOTING"
+		changed = true
+	}
+	if r.CreatedAt.IsZero() && !r.UpdatedAt.IsZero() {
+		r.CreatedAt = r.UpdatedAt
+		changed = true
+	}
+	return changed
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 type DeletedRoom struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -301,6 +608,40 @@ type DeletedRoom struct {
 type CleanupResult struct {
 	Message   string        `json:"message"`
 	Deleted   int           `json:"deleted"`
+	Archived  int           `json:"archived"`
+	DryRun    bool          `json:"dry_run"`
 	Rooms     []DeletedRoom `json:"rooms"`
 	CleanedAt time.Time     `json:"cleaned_at"`
 }
+
+// RoomRecord pairs a room with its document ID, which is not part of Room.
+type RoomRecord struct {
+	ID   string `json:"id"`
+	Room Room   `json:"room"`
+}
+
+// ArchivedRoom is the cold-storage copy of a room written before deletion.
+// Room carries the full document, including the scored ticket queue.
+type ArchivedRoom struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	MemberCount int       `json:"member_count"`
+	TicketCount int       `json:"ticket_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ArchivedAt  time.Time `json:"archived_at"`
+	Room        Room      `json:"room"`
+}
+
+func NewArchivedRoom(id string, room Room, archivedAt time.Time) ArchivedRoom {
+	return ArchivedRoom{
+		ID:          id,
+		Name:        room.Name,
+		MemberCount: len(room.EverJoinedMemberIDs),
+		TicketCount: len(room.TicketQueue),
+		CreatedAt:   room.CreatedAt,
+		UpdatedAt:   room.UpdatedAt,
+		ArchivedAt:  archivedAt,
+		Room:        room,
+	}
+}
@@ -334,3 +334,184 @@ func TestSetTicketQueue_MatchByNameNoJiraKey(t *testing.T) {
 		t.Errorf("expected TicketEstimation.Name == My Ticket, got %s", room.TicketEstimation.Name)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// RepairMemberIndexes() / Backfill() tests
+// ---------------------------------------------------------------------------
+
+func TestRepairMemberIndexes_DedupesMembersAndRebuildsIDs(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{
+		makeMember("1", "3"),
+		makeMember("2", ""),
+		makeMember("1", "5"),
+	}
+	room.MemberIDs = []string{"1", "2", "1", "ghost"}
+	room.EverJoinedMemberIDs = []string{"2", "2"}
+
+	if !room.RepairMemberIndexes() {
+		t.Fatal("expected RepairMemberIndexes to report a change")
+	}
+
+	if len(room.Members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(room.Members))
+	}
+	if room.Members[1].ID != "1" || room.Members[1].EstimatedValue != "5" {
+		t.Errorf("expected last duplicate of member 1 to win, got %+v", room.Members[1])
+	}
+	if !equalStrings(room.MemberIDs, []string{"2", "1"}) {
+		t.Errorf("expected MemberIDs [2 1], got %v", room.MemberIDs)
+	}
+	if !equalStrings(room.EverJoinedMemberIDs, []string{"2", "1"}) {
+		t.Errorf("expected EverJoinedMemberIDs [2 1], got %v", room.EverJoinedMemberIDs)
+	}
+}
+
+func TestRepairMemberIndexes_ConsistentRoomUnchanged(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("1", "")}
+	room.MemberIDs = []string{"1"}
+	room.EverJoinedMemberIDs = []string{"0", "1"}
+
+	if room.RepairMemberIndexes() {
+		t.Error("expected no change for a consistent room")
+	}
+}
+
+func TestBackfill_FillsMissingFields(t *testing.T) {
+	updated := time.Now()
+	room := &Room{Name: "Old", UpdatedAt: updated}
+
+	if !room.Backfill() {
+		t.Fatal("expected Backfill to report a change")
+	}
+	if room.Status != "VOTING" {
+		t.Errorf("expected Status VOTING, got %s", room.Status)
+	}
+	if room.Result == nil || room.Members == nil || room.MemberIDs == nil || room.EverJoinedMemberIDs == nil {
+		t.Error("expected nil collections to be initialized")
+	}
+	if !room.CreatedAt.Equal(updated) {
+		t.Errorf("expected CreatedAt backfilled from UpdatedAt, got %v", room.CreatedAt)
+	}
+	if room.Backfill() {
+		t.Error("expected second Backfill to be a no-op")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Owner() and ChangeDeck() tests
+// ---------------------------------------------------------------------------
+
+func TestOwner_FallsBackToFirstJoiner(t *testing.T) {
+	room := makeRoom()
+	room.EverJoinedMemberIDs = []string{"a", "b"}
+	if !room.IsOwner("a") || room.IsOwner("b") {
+		t.Errorf("expected first joiner to own a room without OwnerID, got %q", room.Owner())
+	}
+
+	room.OwnerID = "b"
+	if !room.IsOwner("b") || room.IsOwner("a") {
+		t.Errorf("expected OwnerID to win, got %q", room.Owner())
+	}
+	if makeRoom().IsOwner("") {
+		t.Error("expected nobody to own an empty legacy room")
+	}
+}
+
+func TestChangeDeck_ClearInvalidKeepsCardsStillOnDeck(t *testing.T) {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3,5,8"
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "8"), makeMember("c", "")}
+	room.UpdateResult()
+
+	withdrawn, err := room.ChangeDeck("1, 2, 3, 5", DeckVotesClearInvalid, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawn) != 1 || withdrawn[0] != "b" {
+		t.Errorf("expected only b's vote withdrawn, got %v", withdrawn)
+	}
+	if room.Members[0].EstimatedValue != "3" || room.Members[1].EstimatedValue != "" {
+		t.Errorf("unexpected votes %+v", room.Members)
+	}
+	if room.Result["8"] != 0 || room.Result["3"] != 1 {
+		t.Errorf("expected result recalculated, got %v", room.Result)
+	}
+	if room.DeskConfig != "1, 2, 3, 5" {
+		t.Errorf("expected deck replaced, got %q", room.DeskConfig)
+	}
+}
+
+func TestChangeDeck_ClearAllWithdrawsEveryVote(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "8")}
+
+	withdrawn, err := room.ChangeDeck("3,8", DeckVotesClearAll, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawn) != 2 || len(room.Result) != 0 {
+		t.Errorf("expected every vote withdrawn, got %v and result %v", withdrawn, room.Result)
+	}
+}
+
+func TestChangeDeck_RejectLeavesRoomUntouched(t *testing.T) {
+	room := makeRoom()
+	room.DeskConfig = "1,2,3"
+	room.Members = []Member{makeMember("a", "1"), makeMember("b", "3")}
+
+	if _, err := room.ChangeDeck("1,2", DeckVotesReject, time.Now()); err != ErrVotesNotOnDeck {
+		t.Fatalf("expected ErrVotesNotOnDeck, got %v", err)
+	}
+	if room.DeskConfig != "1,2,3" || room.Members[1].EstimatedValue != "3" {
+		t.Errorf("expected room unchanged, got %+v", room)
+	}
+	if _, err := room.ChangeDeck("1,2,3,5", DeckVotesReject, time.Now()); err != nil {
+		t.Errorf("expected a superset deck to be accepted, got %v", err)
+	}
+}
+
+func TestJoinRoom_RejoinUpdatesMemberInPlace(t *testing.T) {
+	now := time.Now()
+	room := makeRoom()
+	room.JoinRoom(NewMember("a", "Alice", "a.png", now), now)
+	room.JoinRoom(NewMember("b", "Bob", "", now), now)
+	room.UpdateEstimatedValue(0, "5", now)
+
+	room.JoinRoom(NewMember("a", "Alicia", "new.png", now), now)
+
+	if len(room.Members) != 2 || len(room.MemberIDs) != 2 || len(room.EverJoinedMemberIDs) != 2 {
+		t.Fatalf("expected no duplicates, got members=%d ids=%v ever=%v", len(room.Members), room.MemberIDs, room.EverJoinedMemberIDs)
+	}
+	a := room.Members[0]
+	if a.ID != "a" || a.Name != "Alicia" || a.Picture != "new.png" || a.EstimatedValue != "5" {
+		t.Errorf("expected Alice updated in place with her vote kept, got %+v", a)
+	}
+}
+
+func TestLeave_KeepsEverJoinedAndWithdrawsVote(t *testing.T) {
+	now := time.Now()
+	room := makeRoom()
+	room.JoinRoom(NewMember("a", "Alice", "", now), now)
+	room.JoinRoom(NewMember("b", "Bob", "", now), now)
+	room.UpdateEstimatedValue(0, "5", now)
+	room.UpdateEstimatedValue(1, "8", now)
+	room.UpdateResult()
+
+	if !room.Leave("a", now) {
+		t.Fatal("expected Alice to leave")
+	}
+	if room.CheckMember("a") || len(room.MemberIDs) != 1 || room.MemberIDs[0] != "b" {
+		t.Errorf("expected only Bob left, got %v", room.MemberIDs)
+	}
+	if len(room.EverJoinedMemberIDs) != 2 {
+		t.Errorf("expected Alice to stay in EverJoinedMemberIDs, got %v", room.EverJoinedMemberIDs)
+	}
+	if room.Result["5"] != 0 || room.Result["8"] != 1 {
+		t.Errorf("expected Alice's vote withdrawn, got %v", room.Result)
+	}
+	if room.Leave("a", now) {
+		t.Error("expected leaving twice to report no member")
+	}
+}
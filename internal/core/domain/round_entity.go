@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Round is a revealed round, kept in the room's history.
+type Round struct {
+	// Ticket is the ticket as it was when the cards were revealed, or nil,
+	// without its details, which the queue keeps.
+	Ticket     *TicketEstimation `json:"ticket" firestore:"Ticket"`
+	RevealedAt time.Time         `json:"revealed_at" firestore:"RevealedAt"`
+	Result     map[string]int    `json:"result" firestore:"Result"`
+	// Anonymous rounds never show clients who voted what. Their Votes are
+	// only recorded when the room records votes, for its owner.
+	Anonymous bool              `json:"anonymous" firestore:"Anonymous"`
+	Votes     map[string]string `json:"votes,omitempty" firestore:"Votes"`
+	// DimensionStats and DimensionVotes, member ID to dimension key to card,
+	// are kept for multi-dimensional rooms, the latter under the same rule
+	// as Votes.
+	DimensionStats map[string]DimensionStats    `json:"dimension_stats,omitempty" firestore:"DimensionStats"`
+	DimensionVotes map[string]map[string]string `json:"dimension_votes,omitempty" firestore:"DimensionVotes"`
+	// ConfidenceStats is the round's, and Confidences, member ID to
+	// confidence, is kept under the same rule as Votes.
+	ConfidenceStats *ConfidenceStats `json:"confidence_stats,omitempty" firestore:"ConfidenceStats"`
+	Confidences     map[string]int   `json:"confidences,omitempty" firestore:"Confidences"`
+	// DelphiRound numbers the round among the reveals of its ticket.
+	DelphiRound int `json:"delphi_round,omitempty" firestore:"DelphiRound"`
+	// Async is set on rounds an async session revealed.
+	Async bool `json:"async,omitempty" firestore:"Async"`
+	// Notes are the notes on the round's ticket, made up to its next reveal.
+	// They are not stored with the round but taken from the room's notes;
+	// see FillRoundNotes.
+	Notes []TicketNote `json:"notes,omitempty" firestore:"-"`
+}
+
+var ErrRoundInProgress = errors.New("the voting mode cannot change while an anonymous round has votes")
+
+// SetVotingMode switches anonymous voting and vote recording. Neither may
+// change while an anonymous round has votes: turning anonymity off would
+// link them to members, and recording them would break the promise they
+// were cast under. Starting the next round lifts the restriction.
+func (r *Room) SetVotingMode(anonymous, recordVotes bool, updatedAt time.Time) error {
+	changed := anonymous != r.AnonymousVoting || recordVotes != r.RecordVotes
+	if changed && r.AnonymousVoting && r.hasVotes() {
+		return ErrRoundInProgress
+	}
+	r.AnonymousVoting = anonymous
+	r.RecordVotes = recordVotes
+	r.UpdatedAt = updatedAt
+	return nil
+}
+
+// RecordRound adds the round just revealed to the history, dropping the
+// oldest rounds beyond limit. A limit of zero keeps no history.
+func (r *Room) RecordRound(revealedAt time.Time, limit int) {
+	if limit <= 0 {
+		return
+	}
+	round := Round{
+		RevealedAt:      revealedAt,
+		Result:          map[string]int{},
+		Anonymous:       r.AnonymousVoting,
+		DimensionStats:  r.DimensionStats,
+		ConfidenceStats: r.ConfidenceStats,
+		DelphiRound:     len(r.DelphiRounds),
+	}
+	for value, count := range r.Result {
+		round.Result[value] = count
+	}
+	if r.TicketEstimation != nil {
+		round.Ticket = roundTicket(*r.TicketEstimation)
+	}
+	if !r.AnonymousVoting || r.RecordVotes {
+		round.Votes = map[string]string{}
+		for _, m := range r.Members {
+			if m.EstimatedValue != "" {
+				round.Votes[m.ID] = m.EstimatedValue
+			}
+			if m.Confidence > 0 {
+				if round.Confidences == nil {
+					round.Confidences = map[string]int{}
+				}
+				round.Confidences[m.ID] = m.Confidence
+			}
+		}
+		if r.MultiDimensional() {
+			round.DimensionVotes = map[string]map[string]string{}
+			for _, m := range r.Members {
+				if len(m.DimensionValues) > 0 {
+					round.DimensionVotes[m.ID] = m.DimensionValues
+				}
+			}
+		}
+	}
+
+	r.addRound(round, limit)
+}
+
+// roundTicket is the snapshot of t kept in the history, without its details.
+func roundTicket(t TicketEstimation) *TicketEstimation {
+	t.SetDetails(TicketDetails{})
+	return &t
+}
+
+func (r *Room) addRound(round Round, limit int) {
+	if round.Ticket != nil {
+		round.Notes = r.notesOn(ticketKey(round.Ticket))
+	}
+	r.Rounds = append(r.Rounds, round)
+	if over := len(r.Rounds) - limit; over > 0 {
+		r.Rounds = append(r.Rounds[:0:0], r.Rounds[over:]...)
+	}
+}
+
+// View returns the room as anyone may see it; see ViewFor.
+func (r Room) View() Room {
+	return r.ViewFor("")
+}
+
+// ViewFor returns the room as the user uid may see it. Every member carries
+// HasVoted, and until the cards are revealed the result is hidden and uid
+// sees no vote but their own. An anonymous room never links votes to
+// members, not even after the reveal, and its rounds in the history lose
+// their votes.
+func (r Room) ViewFor(uid string) Room {
+	revealed := r.Status == "REVEALED_CARDS"
+	view := r
+	view.Members = make([]Member, len(r.Members))
+	for i, m := range r.Members {
+		m.HasVoted = m.EstimatedValue != ""
+		if m.ID != uid && (r.AnonymousVoting || !revealed) {
+			m.EstimatedValue = ""
+			m.DimensionValues = nil
+			m.Confidence = 0
+		}
+		view.Members[i] = m
+	}
+	if !revealed {
+		view.Result = map[string]int{}
+	}
+	if r.AnonymousVoting {
+		view.ConfidenceStats = anonymousConfidence(r.ConfidenceStats)
+	}
+	if r.Async != nil {
+		view.Async = r.Async.view(uid, r.AnonymousVoting)
+	}
+
+	view.Rounds = make([]Round, len(r.Rounds))
+	for i, round := range r.Rounds {
+		if round.Anonymous {
+			round.Votes = nil
+			round.DimensionVotes = nil
+			round.Confidences = nil
+			round.ConfidenceStats = anonymousConfidence(round.ConfidenceStats)
+		}
+		view.Rounds[i] = round
+	}
+	return view
+}
+
+// anonymousConfidence drops the names of low-confidence voters.
+func anonymousConfidence(stats *ConfidenceStats) *ConfidenceStats {
+	if stats == nil {
+		return nil
+	}
+	anonymous := *stats
+	anonymous.LowConfidenceMembers = nil
+	return &anonymous
+}
+
+func (r *Room) hasVotes() bool {
+	for _, m := range r.Members {
+		if m.HasVote() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func makeAnonymousRoom() *Room {
+	room := makeRoom()
+	room.AnonymousVoting = true
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", ""), makeMember("c", "5")}
+	room.UpdateResult()
+	return room
+}
+
+func TestView_AnonymousRoomHidesWhoVotedWhat(t *testing.T) {
+	room := makeAnonymousRoom()
+
+	view := room.View()
+	for _, m := range view.Members {
+		if m.EstimatedValue != "" {
+			t.Errorf("expected %s's vote hidden, got %q", m.ID, m.EstimatedValue)
+		}
+	}
+	if !view.Members[0].HasVoted || view.Members[1].HasVoted || !view.Members[2].HasVoted {
+		t.Errorf("expected has_voted to follow the votes, got %+v", view.Members)
+	}
+	if len(view.Result) != 0 {
+		t.Errorf("expected the result hidden before the reveal, got %v", view.Result)
+	}
+	if room.Members[0].EstimatedValue != "3" {
+		t.Error("expected View to leave the room untouched")
+	}
+
+	room.Status = "REVEALED_CARDS"
+	if view := room.View(); view.Result["3"] != 1 || view.Result["5"] != 1 {
+		t.Errorf("expected the result shown after the reveal, got %v", view.Result)
+	}
+}
+
+func TestRecordRound_KeepsAnonymousVotesOnlyWhenRecording(t *testing.T) {
+	now := time.Now()
+	room := makeAnonymousRoom()
+	room.RecordRound(now, 10)
+	if room.Rounds[0].Votes != nil {
+		t.Errorf("expected no votes recorded, got %v", room.Rounds[0].Votes)
+	}
+
+	room.RecordVotes = true
+	room.RecordRound(now, 10)
+	if votes := room.Rounds[1].Votes; votes["a"] != "3" || votes["c"] != "5" || len(votes) != 2 {
+		t.Errorf("expected the cast votes recorded, got %v", votes)
+	}
+	if view := room.View(); view.Rounds[1].Votes != nil {
+		t.Error("expected the view to strip recorded anonymous votes")
+	}
+}
+
+func TestRecordRound_TrimsToLimit(t *testing.T) {
+	start := time.Now()
+	room := makeRoom()
+	for i := 0; i < 5; i++ {
+		room.RecordRound(start.Add(time.Duration(i)*time.Minute), 3)
+	}
+	if len(room.Rounds) != 3 || !room.Rounds[0].RevealedAt.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("expected the three latest rounds, got %+v", room.Rounds)
+	}
+
+	room.RecordRound(start, 0)
+	if len(room.Rounds) != 3 {
+		t.Error("expected a zero limit to record nothing")
+	}
+}
+
+func TestRecordRound_SnapshotsTicketWithoutDetails(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1", FinalScore: "5"}
+	room.TicketEstimation.SetDetails(TicketDetails{Description: "Story", Labels: []string{"api"}})
+
+	room.RecordRound(time.Now(), 10)
+
+	if ticket := room.Rounds[0].Ticket; ticket.Name != "T-1" || ticket.FinalScore != "5" || ticket.Description != "" || ticket.Labels != nil {
+		t.Errorf("expected the ticket without its details, got %+v", ticket)
+	}
+	if room.TicketEstimation.Description != "Story" {
+		t.Error("expected the active ticket to keep its details")
+	}
+}
+
+func TestSetVotingMode_RefusesChangesWhileAnonymousVotesAreCast(t *testing.T) {
+	now := time.Now()
+	room := makeAnonymousRoom()
+	if err := room.SetVotingMode(false, false, now); err != ErrRoundInProgress {
+		t.Fatalf("expected ErrRoundInProgress, got %v", err)
+	}
+	if err := room.SetVotingMode(true, false, now); err != nil {
+		t.Errorf("expected an unchanged mode to be accepted, got %v", err)
+	}
+
+	room.Restart(now)
+	if err := room.SetVotingMode(false, false, now); err != nil || room.AnonymousVoting {
+		t.Errorf("expected the next round to allow the change, got %v", err)
+	}
+}
+
+func TestViewFor_ShowsOnlyOwnVoteUntilReveal(t *testing.T) {
+	room := makeRoom()
+	room.Members = []Member{makeMember("a", "3"), makeMember("b", "5")}
+	room.UpdateResult()
+
+	view := room.ViewFor("a")
+	if view.Members[0].EstimatedValue != "3" || view.Members[1].EstimatedValue != "" || !view.Members[1].HasVoted {
+		t.Errorf("expected a to see only their own vote, got %+v", view.Members)
+	}
+	if len(view.Result) != 0 {
+		t.Errorf("expected the result hidden before the reveal, got %v", view.Result)
+	}
+
+	room.Status = "REVEALED_CARDS"
+	if view := room.ViewFor("a"); view.Members[1].EstimatedValue != "5" {
+		t.Errorf("expected every vote shown after the reveal, got %+v", view.Members)
+	}
+}
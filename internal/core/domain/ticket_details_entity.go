@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Size limits of a ticket's details, to keep room documents small. A room
+// queues at most MaxTicketQueue tickets, and the details of its active and
+// queued tickets add up to at most MaxTicketDetailsTotal bytes.
+const (
+	MaxTicketDescription  = 10000
+	MaxAcceptanceCriteria = 20
+	MaxCriterionLength    = 500
+	MaxTicketLabels       = 20
+	MaxLabelLength        = 50
+	MaxTicketLinks        = 20
+	MaxLinkLength         = 2048
+	MaxTicketQueue        = 200
+	MaxTicketDetailsTotal = 256 * 1024
+)
+
+var (
+	ErrTicketNotFound = errors.New("no such ticket")
+	ErrNoActiveTicket = errors.New("no active ticket")
+	// ErrTicketDetailsTooLarge is returned when details would take the
+	// room's tickets over MaxTicketDetailsTotal.
+	ErrTicketDetailsTooLarge = fmt.Errorf("ticket details exceed %d bytes in total", MaxTicketDetailsTotal)
+)
+
+// TicketDetails is what a ticket says beyond its name: a Markdown
+// description, acceptance criteria, labels and links to the story or its
+// attachments.
+type TicketDetails struct {
+	Description        string
+	AcceptanceCriteria []string
+	Labels             []string
+	Links              []string
+}
+
+// Validate checks the limits above and that every link is an absolute http
+// or https URL.
+func (d TicketDetails) Validate() error {
+	if len(d.Description) > MaxTicketDescription {
+		return fmt.Errorf("description exceeds %d characters", MaxTicketDescription)
+	}
+	if len(d.AcceptanceCriteria) > MaxAcceptanceCriteria {
+		return fmt.Errorf("at most %d acceptance criteria", MaxAcceptanceCriteria)
+	}
+	for _, c := range d.AcceptanceCriteria {
+		if len(c) > MaxCriterionLength {
+			return fmt.Errorf("acceptance criterion exceeds %d characters", MaxCriterionLength)
+		}
+	}
+	if len(d.Labels) > MaxTicketLabels {
+		return fmt.Errorf("at most %d labels", MaxTicketLabels)
+	}
+	for _, l := range d.Labels {
+		if len(l) > MaxLabelLength {
+			return fmt.Errorf("label exceeds %d characters", MaxLabelLength)
+		}
+	}
+	if len(d.Links) > MaxTicketLinks {
+		return fmt.Errorf("at most %d links", MaxTicketLinks)
+	}
+	for _, link := range d.Links {
+		if len(link) > MaxLinkLength {
+			return fmt.Errorf("link exceeds %d characters", MaxLinkLength)
+		}
+		u, err := url.Parse(strings.TrimSpace(link))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("link %q is not an http or https URL", link)
+		}
+	}
+	return nil
+}
+
+// Size is the bytes the details take.
+func (d TicketDetails) Size() int {
+	size := len(d.Description)
+	for _, list := range [][]string{d.AcceptanceCriteria, d.Labels, d.Links} {
+		for _, s := range list {
+			size += len(s)
+		}
+	}
+	return size
+}
+
+// ValidateTickets checks the active ticket and the queue together against
+// MaxTicketQueue and MaxTicketDetailsTotal.
+func ValidateTickets(active *TicketEstimation, queue []TicketEstimation) error {
+	if len(queue) > MaxTicketQueue {
+		return fmt.Errorf("at most %d queued tickets", MaxTicketQueue)
+	}
+	if ticketDetailsSize(active, queue) > MaxTicketDetailsTotal {
+		return ErrTicketDetailsTooLarge
+	}
+	return nil
+}
+
+func ticketDetailsSize(active *TicketEstimation, queue []TicketEstimation) int {
+	size := 0
+	if active != nil {
+		size += active.Details().Size()
+	}
+	for i := range queue {
+		size += queue[i].Details().Size()
+	}
+	return size
+}
+
+// Sanitized strips control characters, and line breaks outside the
+// description, trims every entry and drops the empty ones and repeated
+// labels. The description stays Markdown: clients render it without raw
+// HTML.
+func (d TicketDetails) Sanitized() TicketDetails {
+	clean := TicketDetails{Description: strings.TrimSpace(stripControl(d.Description, true))}
+	for _, c := range d.AcceptanceCriteria {
+		if c = strings.TrimSpace(stripControl(c, false)); c != "" {
+			clean.AcceptanceCriteria = append(clean.AcceptanceCriteria, c)
+		}
+	}
+	seen := map[string]bool{}
+	for _, l := range d.Labels {
+		l = strings.TrimSpace(stripControl(l, false))
+		if l != "" && !seen[strings.ToLower(l)] {
+			seen[strings.ToLower(l)] = true
+			clean.Labels = append(clean.Labels, l)
+		}
+	}
+	for _, link := range d.Links {
+		if link = strings.TrimSpace(stripControl(link, false)); link != "" {
+			clean.Links = append(clean.Links, link)
+		}
+	}
+	return clean
+}
+
+func stripControl(s string, keepLines bool) string {
+	s = strings.ToValidUTF8(s, "")
+	return strings.Map(func(c rune) rune {
+		if keepLines && (c == '\n' || c == '\t') {
+			return c
+		}
+		if unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, s)
+}
+
+// Details returns the ticket's details.
+func (t *TicketEstimation) Details() TicketDetails {
+	return TicketDetails{
+		Description:        t.Description,
+		AcceptanceCriteria: t.AcceptanceCriteria,
+		Labels:             t.Labels,
+		Links:              t.Links,
+	}
+}
+
+// SetDetails replaces the ticket's details with d.
+func (t *TicketEstimation) SetDetails(d TicketDetails) {
+	t.Description = d.Description
+	t.AcceptanceCriteria = d.AcceptanceCriteria
+	t.Labels = d.Labels
+	t.Links = d.Links
+}
+
+// SetTicketDetails replaces the details of the ticket with the given Jira key
+// or name, or of the active ticket when key is empty, both where it is active
+// and in the queue. It fails with ErrTicketDetailsTooLarge, changing nothing,
+// when the room's tickets would exceed MaxTicketDetailsTotal.
+func (r *Room) SetTicketDetails(key string, d TicketDetails, updatedAt time.Time) error {
+	if key == "" {
+		if r.TicketEstimation == nil {
+			return ErrNoActiveTicket
+		}
+		key = ticketKey(r.TicketEstimation)
+	}
+	var targets []*TicketEstimation
+	if r.TicketEstimation != nil && ticketKey(r.TicketEstimation) == key {
+		targets = append(targets, r.TicketEstimation)
+	}
+	for i := range r.TicketQueue {
+		if ticketKey(&r.TicketQueue[i]) == key {
+			targets = append(targets, &r.TicketQueue[i])
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("%w: %q", ErrTicketNotFound, key)
+	}
+	size := ticketDetailsSize(r.TicketEstimation, r.TicketQueue)
+	for _, t := range targets {
+		size += d.Size() - t.Details().Size()
+	}
+	if size > MaxTicketDetailsTotal {
+		return ErrTicketDetailsTooLarge
+	}
+	for _, t := range targets {
+		t.SetDetails(d)
+	}
+	r.UpdatedAt = updatedAt
+	return nil
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTicketDetails_Validate(t *testing.T) {
+	cases := map[string]TicketDetails{
+		"long description": {Description: strings.Repeat("x", MaxTicketDescription+1)},
+		"long criterion":   {AcceptanceCriteria: []string{strings.Repeat("x", MaxCriterionLength+1)}},
+		"too many labels":  {Labels: make([]string, MaxTicketLabels+1)},
+		"script link":      {Links: []string{"javascript:alert(1)"}},
+		"relative link":    {Links: []string{"/browse/T-1"}},
+	}
+	for name, d := range cases {
+		t.Run(name, func(t *testing.T) {
+			if d.Validate() == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	ok := TicketDetails{Description: "# Story", Links: []string{" https://example.atlassian.net/browse/T-1 "}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("expected valid details, got %v", err)
+	}
+}
+
+func TestTicketDetails_Sanitized(t *testing.T) {
+	d := TicketDetails{
+		Description:        "  Line one\n\tLine two\x00\x1b[31m  ",
+		AcceptanceCriteria: []string{"Works\noffline", "  ", "Fast"},
+		Labels:             []string{"backend", " Backend ", "api\x07"},
+		Links:              []string{" https://example.com/a ", ""},
+	}
+
+	got := d.Sanitized()
+
+	if got.Description != "Line one\n\tLine two[31m" {
+		t.Errorf("unexpected description %q", got.Description)
+	}
+	if len(got.AcceptanceCriteria) != 2 || got.AcceptanceCriteria[0] != "Worksoffline" {
+		t.Errorf("unexpected criteria %q", got.AcceptanceCriteria)
+	}
+	if len(got.Labels) != 2 || got.Labels[1] != "api" {
+		t.Errorf("expected repeated labels dropped, got %q", got.Labels)
+	}
+	if len(got.Links) != 1 || got.Links[0] != "https://example.com/a" {
+		t.Errorf("unexpected links %q", got.Links)
+	}
+}
+
+func TestSetTicketDetails_UpdatesActiveAndQueuedTicket(t *testing.T) {
+	room := makeRoom()
+	room.TicketEstimation = &TicketEstimation{Name: "T-1"}
+	room.TicketQueue = []TicketEstimation{{Name: "T-1"}, {Name: "T-2"}}
+	now := time.Now()
+
+	if err := room.SetTicketDetails("", TicketDetails{Description: "Story"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if room.TicketEstimation.Description != "Story" || room.TicketQueue[0].Description != "Story" || room.TicketQueue[1].Description != "" {
+		t.Errorf("expected only T-1 described, got %+v %+v", room.TicketEstimation, room.TicketQueue)
+	}
+
+	room.SetTicketDetails("T-2", TicketDetails{Labels: []string{"api"}}, now)
+	if len(room.TicketQueue[1].Labels) != 1 {
+		t.Error("expected the queued ticket labelled")
+	}
+	if err := room.SetTicketDetails("T-9", TicketDetails{}, now); err == nil {
+		t.Error("expected an unknown ticket refused")
+	}
+}
+
+func TestValidateTickets_CapsQueueAndTotalDetails(t *testing.T) {
+	if err := ValidateTickets(nil, make([]TicketEstimation, MaxTicketQueue+1)); err == nil {
+		t.Error("expected an overlong queue refused")
+	}
+
+	described := TicketEstimation{Name: "T-1", Description: strings.Repeat("x", MaxTicketDescription)}
+	queue := make([]TicketEstimation, MaxTicketDetailsTotal/MaxTicketDescription+1)
+	for i := range queue {
+		queue[i] = described
+	}
+	if err := ValidateTickets(nil, queue); err != ErrTicketDetailsTooLarge {
+		t.Errorf("expected ErrTicketDetailsTooLarge, got %v", err)
+	}
+	if err := ValidateTickets(&described, queue[:10]); err != nil {
+		t.Errorf("expected a small queue accepted, got %v", err)
+	}
+}
+
+func TestSetTicketDetails_RefusesDetailsOverTheTotal(t *testing.T) {
+	room := makeRoom()
+	full := TicketDetails{Description: strings.Repeat("x", MaxTicketDescription)}
+	for i := 0; i < MaxTicketDetailsTotal/MaxTicketDescription; i++ {
+		ticket := TicketEstimation{Name: strings.Repeat("T", i+1)}
+		ticket.SetDetails(full)
+		room.TicketQueue = append(room.TicketQueue, ticket)
+	}
+	room.TicketQueue = append(room.TicketQueue, TicketEstimation{Name: "last"})
+
+	err := room.SetTicketDetails("last", full, time.Now())
+	if err != ErrTicketDetailsTooLarge || room.TicketQueue[len(room.TicketQueue)-1].Description != "" {
+		t.Errorf("expected ErrTicketDetailsTooLarge and nothing changed, got %v", err)
+	}
+}
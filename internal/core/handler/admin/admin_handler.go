@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	adminService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/admin"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+const defaultCloseReason = "closed by administrator"
+
+func ListRoomsHandler(c *fiber.Ctx) error {
+	filter, err := parseRoomFilter(c)
+	if err != nil {
+		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	rooms, err := adminService.ListRooms(filter)
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rooms})
+}
+
+func GetRoomHandler(c *fiber.Ctx) error {
+	detail, err := adminService.GetRoom(c.Params("roomId"))
+	if err != nil {
+		return roomError(c, err)
+	}
+	return c.JSON(fiber.Map{"data": detail})
+}
+
+func CloseRoomSocketsHandler(c *fiber.Ctx) error {
+	req := closeSocketsRequest{Reason: defaultCloseReason}
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := req.Validate(); err != nil {
+			return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	roomId := c.Params("roomId")
+	closed := adminService.CloseRoomSockets(roomId, req.Reason)
+	logger.Info("admin closed room sockets", "roomId", roomId, "closed", closed, "admin", c.Locals("admin_subject"))
+	return c.JSON(fiber.Map{"closed": closed})
+}
+
+// DeleteRoomHandler deletes a room; with ?archive=true it is copied to the
+// archived_rooms collection first.
+func DeleteRoomHandler(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	archive := c.QueryBool("archive", false)
+
+	closed, err := adminService.DeleteRoom(roomId, archive)
+	if err != nil {
+		return roomError(c, err)
+	}
+	logger.Info("admin deleted room", "roomId", roomId, "archive", archive, "admin", c.Locals("admin_subject"))
+	return c.JSON(fiber.Map{"deleted": roomId, "archived": archive, "closed_sockets": closed})
+}
+
+func UserRoomsHandler(c *fiber.Ctx) error {
+	rooms, err := adminService.RoomsJoinedBy(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rooms})
+}
+
+func roomError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, adminService.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	adminService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/admin"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 500
+	defaultIdleAfter = 15 * time.Minute
+)
+
+type closeSocketsRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (r *closeSocketsRequest) Validate() error {
+	if len(r.Reason) > 100 {
+		return errors.New("reason exceeds 100 characters")
+	}
+	return nil
+}
+
+// parseRoomFilter reads ?state=active|idle&idle_after=15m&min_members=&max_members=&limit=
+func parseRoomFilter(c *fiber.Ctx) (adminService.RoomFilter, error) {
+	filter := adminService.RoomFilter{
+		State:      c.Query("state"),
+		IdleAfter:  defaultIdleAfter,
+		MinMembers: c.QueryInt("min_members", 0),
+		MaxMembers: c.QueryInt("max_members", -1),
+		Limit:      c.QueryInt("limit", defaultListLimit),
+	}
+
+	switch filter.State {
+	case adminService.StateAny, adminService.StateActive, adminService.StateIdle:
+	default:
+		return filter, errors.New("state must be active or idle")
+	}
+	if raw := c.Query("idle_after"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return filter, errors.New("idle_after must be a positive duration such as 15m")
+		}
+		filter.IdleAfter = d
+	}
+	if filter.MinMembers < 0 {
+		return filter, errors.New("min_members must not be negative")
+	}
+	if filter.MaxMembers >= 0 && filter.MaxMembers < filter.MinMembers {
+		return filter, errors.New("max_members must be greater than or equal to min_members")
+	}
+	if filter.Limit < 1 || filter.Limit > maxListLimit {
+		return filter, errors.New("limit must be between 1 and 500")
+	}
+	return filter, nil
+}
@@ -1,11 +1,85 @@
 package health
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/repository"
 )
 
+var shuttingDown atomic.Bool
+
+type dependencyCheck struct {
+	name  string
+	probe func(ctx context.Context) error
+}
+
+var dependencies = []dependencyCheck{
+	{name: "firestore", probe: repository.Ping},
+}
+
+// MarkShuttingDown makes readiness fail so load balancers stop routing new
+// traffic to this instance while in-flight requests drain.
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+}
+
 func HealthCheckHandler(c *fiber.Ctx) error {
 	return c.Status(http.StatusOK).SendString("Healthy")
 }
+
+// LivenessHandler reports whether the process is up. It never checks
+// dependencies, so a Firestore outage does not get the instance restarted.
+func LivenessHandler(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(fiber.Map{"status": statusOK})
+}
+
+// ReadinessHandler probes every dependency with a bounded timeout and
+// reports per-dependency status. It fails while the server is shutting down.
+func ReadinessHandler(c *fiber.Ctx) error {
+	if shuttingDown.Load() {
+		return c.Status(http.StatusServiceUnavailable).JSON(readinessResponse{
+			Status: statusShuttingDown,
+			Checks: map[string]dependencyStatus{},
+		})
+	}
+
+	res := readinessResponse{
+		Status: statusOK,
+		Checks: make(map[string]dependencyStatus, len(dependencies)),
+	}
+	for _, dep := range dependencies {
+		status := runProbe(c.UserContext(), dep)
+		if status.Status != statusOK {
+			res.Status = statusUnavailable
+		}
+		res.Checks[dep.name] = status
+	}
+
+	code := http.StatusOK
+	if res.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(res)
+}
+
+func runProbe(parent context.Context, dep dependencyCheck) dependencyStatus {
+	ctx, cancel := context.WithTimeout(parent, configs.Conf.ReadinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dep.probe(ctx)
+	status := dependencyStatus{
+		Status:    statusOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		status.Status = statusUnavailable
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,18 @@
+package health
+
+const (
+	statusOK           = "ok"
+	statusUnavailable  = "unavailable"
+	statusShuttingDown = "shutting_down"
+)
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status string                      `json:"status"`
+	Checks map[string]dependencyStatus `json:"checks"`
+}
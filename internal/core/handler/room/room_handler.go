@@ -1,11 +1,20 @@
 package room
 
 import (
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/raksitnongbua/planning-poker-service/constants"
+	participantauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/participant"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/cleanup"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/profile"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomaccess "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_access"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/timer"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
 )
 
 func CreateNewRoomHandler(c *fiber.Ctx) error {
@@ -17,7 +26,7 @@ func CreateNewRoomHandler(c *fiber.Ctx) error {
 	if req.RoomName == "" || req.HostingID == "" || req.DeskConfig == "" {
 		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": "Missing required fields"})
 	}
-	roomID, err := room.CreateNewRoom(req.RoomName, req.DeskConfig)
+	roomID, err := room.CreateNewRoom(req.RoomName, req.DeskConfig, req.HostingID)
 	if err != nil {
 		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -29,7 +38,10 @@ func CreateNewRoomHandler(c *fiber.Ctx) error {
 }
 
 func CleanupExpiredRoomsHandler(c *fiber.Ctx) error {
-	result, err := room.CleanupExpiredRooms()
+	opts := cleanup.OptionsFromConfig()
+	opts.DryRun = c.QueryBool("dry_run", opts.DryRun)
+
+	result, err := cleanup.Run(opts)
 	if err != nil {
 		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -37,22 +49,134 @@ func CleanupExpiredRoomsHandler(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// KickMemberHandler lets the room's owner remove a member, tells the room and
+// closes the member's connections. With ban_seconds in the body the owner also
+// bans them, so they can neither join nor connect until the ban expires. The
+// route must sit behind participantauth.RequireParticipant.
 func KickMemberHandler(c *fiber.Ctx) error {
 	roomId := c.Params("roomId")
 	memberID := c.Params("memberId")
 	if roomId == "" || memberID == "" {
 		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": "Missing required fields"})
 	}
+	req, err := unmarshalKickRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	current, ferr := ownedRoom(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"error": ferr.Message})
+	}
+	uid := participantauth.UID(c)
+	banFor := time.Duration(req.BanSeconds) * time.Second
+	if banFor > 0 && current.IsOwner(memberID) {
+		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": "the room owner cannot be banned"})
+	}
 
-	roomInfo, err := room.KickMember(roomId, memberID)
+	var roomInfo domain.Room
+	var closed int
+	roomhub.Serialize(roomId, func() {
+		roomInfo, err = room.KickMember(roomId, memberID, uid, banFor)
+		if err != nil {
+			return
+		}
+		roomhub.Broadcast(roomId, roomhub.MemberKicked(roomInfo, memberID, time.Now()))
+		closed = roomhub.CloseUser(roomId, memberID, "kicked from room")
+	})
 	if err != nil {
-		if err.Error() == "member not found" {
+		if errors.Is(err, room.ErrMemberNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
 		}
+		if errors.Is(err, room.ErrRoomNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ROOM_NOT_FOUND"})
+		}
 		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"data": roomInfo})
+	logger.Info("member kicked", "roomId", roomId, "memberId", memberID, "by", uid, "banSeconds", req.BanSeconds, "closed", closed)
+
+	return c.JSON(fiber.Map{"data": roomInfo.ViewFor(uid)})
+}
+
+// ListBansHandler lists the room's active bans for its owner. The route must
+// sit behind participantauth.RequireParticipant.
+func ListBansHandler(c *fiber.Ctx) error {
+	roomInfo, ferr := ownedRoom(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"error": ferr.Message})
+	}
+	return c.JSON(fiber.Map{"data": roomInfo.ActiveBans(time.Now())})
+}
+
+// RoundHistoryHandler returns the room's revealed rounds to its owner, with
+// the votes of anonymous rounds the room recorded. The route must sit behind
+// participantauth.RequireParticipant.
+func RoundHistoryHandler(c *fiber.Ctx) error {
+	roomInfo, ferr := ownedRoom(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"error": ferr.Message})
+	}
+	rounds := roomInfo.Rounds
+	if rounds == nil {
+		rounds = []domain.Round{}
+	}
+	return c.JSON(fiber.Map{"data": rounds})
+}
+
+// LiftBanHandler lets a banned user back in. The route must sit behind
+// participantauth.RequireParticipant.
+func LiftBanHandler(c *fiber.Ctx) error {
+	if _, ferr := ownedRoom(c); ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"error": ferr.Message})
+	}
+
+	lifted, err := room.LiftBan(c.Params("roomId"), c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !lifted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "BAN_NOT_FOUND"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func GetRoomHandler(c *fiber.Ctx) error {
+	roomInfo, err := room.FindRoom(c.Params("roomId"))
+	if errors.Is(err, room.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ROOM_NOT_FOUND"})
+	}
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	uid := participantauth.UID(c)
+	if !roomInfo.CanView(uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "ROOM_ACCESS_DENIED"})
+	}
+	return c.JSON(fiber.Map{"data": roomInfo.ViewFor(uid)})
+}
+
+// CreateInviteHandler lets the room's owner sign an invite link. The route
+// must sit behind participantauth.RequireParticipant.
+func CreateInviteHandler(c *fiber.Ctx) error {
+	req, err := unmarshalInviteRequest(c.Body())
+	if err != nil {
+		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+	roomInfo, ferr := ownedRoom(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"error": ferr.Message})
+	}
+
+	invite, err := roomaccess.CreateInvite(c.Params("roomId"), roomInfo.PasscodeHash, time.Duration(req.TTLSeconds)*time.Second)
+	if errors.Is(err, roomaccess.ErrInvitesDisabled) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": invite})
 }
 
 func GetRecentRoomsHandler(c *fiber.Ctx) error {
@@ -84,3 +208,19 @@ func GetRecentRoomsHandler(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"data": rooms})
 }
+
+// ownedRoom loads the room in the URL on behalf of its owner, failing with
+// ROOM_NOT_FOUND or NOT_ROOM_OWNER.
+func ownedRoom(c *fiber.Ctx) (domain.Room, *fiber.Error) {
+	roomInfo, err := room.FindRoom(c.Params("roomId"))
+	if errors.Is(err, room.ErrRoomNotFound) {
+		return domain.Room{}, fiber.NewError(fiber.StatusNotFound, "ROOM_NOT_FOUND")
+	}
+	if err != nil {
+		return domain.Room{}, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if !roomInfo.IsOwner(participantauth.UID(c)) {
+		return domain.Room{}, fiber.NewError(fiber.StatusForbidden, "NOT_ROOM_OWNER")
+	}
+	return roomInfo, nil
+}
@@ -39,3 +39,45 @@ func (r *roomRequest) Validate() error {
 	}
 	return nil
 }
+
+type inviteRequest struct {
+	// TTLSeconds is optional; zero or anything past INVITE_TTL means
+	// INVITE_TTL.
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+func unmarshalInviteRequest(data []byte) (inviteRequest, error) {
+	var r inviteRequest
+	if len(data) == 0 {
+		return r, nil
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+	if r.TTLSeconds < 0 {
+		return r, errors.New("ttl_seconds must not be negative")
+	}
+	return r, nil
+}
+
+// maxBanSeconds caps a ban at a year.
+const maxBanSeconds = 365 * 24 * 60 * 60
+
+type kickRequest struct {
+	// BanSeconds also bans the member for that long; zero only kicks.
+	BanSeconds int64 `json:"ban_seconds"`
+}
+
+func unmarshalKickRequest(data []byte) (kickRequest, error) {
+	var r kickRequest
+	if len(data) == 0 {
+		return r, nil
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+	if r.BanSeconds < 0 || r.BanSeconds > maxBanSeconds {
+		return r, errors.New("ban_seconds must be between 0 and one year")
+	}
+	return r, nil
+}
@@ -0,0 +1,104 @@
+package roomevents
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+)
+
+var errStreamClosed = errors.New("event stream closed")
+
+// eventConn adapts a Server-Sent Events response to roomhub.Conn. Each frame
+// becomes one event named after its action, with the frame's seq as the
+// event id so EventSource sends it back as Last-Event-ID on reconnect.
+// Frames without a seq leave the id alone.
+type eventConn struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closed bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventConn(w *bufio.Writer) *eventConn {
+	return &eventConn{w: w, done: make(chan struct{})}
+}
+
+func (e *eventConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	event, id := "message", int64(0)
+	if m, ok := v.(roomhub.Message); ok {
+		event, id = m.Action, m.Seq
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errStreamClosed
+	}
+	fmt.Fprintf(e.w, "event: %s\n", event)
+	if id > 0 {
+		fmt.Fprintf(e.w, "id: %d\n", id)
+	}
+	fmt.Fprintf(e.w, "data: %s\n\n", data)
+	return e.flushLocked()
+}
+
+// Close ends the stream. EventSource reconnects on its own, so the reason is
+// only logged by the caller.
+func (e *eventConn) Close(string) error {
+	e.closeOnce.Do(func() { close(e.done) })
+	return nil
+}
+
+// keepAlive sends a comment every WS_PING_INTERVAL so proxies keep the
+// response open and a vanished client is noticed on the next write. It
+// returns once the stream is closed or a write fails, after which nothing
+// more is written.
+func (e *eventConn) keepAlive(client *roomhub.Client) {
+	ticker := time.NewTicker(configs.Conf.WSPingInterval)
+	defer func() {
+		ticker.Stop()
+		e.mu.Lock()
+		e.closed = true
+		e.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.mu.Lock()
+			err := errStreamClosed
+			if !e.closed {
+				_, _ = e.w.WriteString(": keep-alive\n\n")
+				err = e.flushLocked()
+			}
+			e.mu.Unlock()
+			if err != nil {
+				return
+			}
+			roomhub.RefreshPresence(client.RoomID, client.UID)
+		}
+	}
+}
+
+func (e *eventConn) flushLocked() error {
+	if err := e.w.Flush(); err != nil {
+		e.closed = true
+		e.closeOnce.Do(func() { close(e.done) })
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package roomevents
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+)
+
+func TestEventConn_WritesFramesAsEvents(t *testing.T) {
+	var out bytes.Buffer
+	conn := newEventConn(bufio.NewWriter(&out))
+
+	if err := conn.WriteJSON(roomhub.Message{Action: roomhub.ActionVoteCast, Seq: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(roomhub.Message{Action: roomhub.ActionNeedToJoin}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "event: VOTE_CAST\nid: 42\ndata: {\"action\":\"VOTE_CAST\",\"payload\":null,\"seq\":42}\n\n" +
+		"event: NEED_TO_JOIN\ndata: {\"action\":\"NEED_TO_JOIN\",\"payload\":null}\n\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestEventConn_RejectsWritesAfterClose(t *testing.T) {
+	saved := configs.Conf
+	t.Cleanup(func() { configs.Conf = saved })
+	configs.Conf.WSPingInterval = time.Hour
+
+	conn := newEventConn(bufio.NewWriter(&bytes.Buffer{}))
+	conn.Close("room closed")
+	conn.keepAlive(roomhub.NewClient(conn, "room", "", ""))
+
+	if err := conn.WriteJSON(roomhub.Message{Action: roomhub.ActionUpdateRoom}); err != errStreamClosed {
+		t.Errorf("expected errStreamClosed, got %v", err)
+	}
+}
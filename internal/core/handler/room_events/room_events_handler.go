@@ -0,0 +1,76 @@
+package roomevents
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	participantauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/participant"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// StreamHandler streams a room's events as Server-Sent Events, for clients
+// that cannot use WebSockets. Subscribers get the same frames as sockets,
+// including the snapshot or replay on connect, and act through the REST
+// endpoints. The route must sit behind participantauth.Identify; anonymous
+// callers may watch a public room but have no presence. A private room is
+// only streamed to its members, who join it over REST first.
+func StreamHandler(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	loadRoom := func() (domain.Room, error) { return roomService.FindRoom(roomId) }
+	roomInfo, err := loadRoom()
+	if errors.Is(err, roomService.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ROOM_NOT_FOUND"})
+	}
+	if err != nil {
+		return c.Status(fiber.ErrInternalServerError.Code).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	uid := participantauth.UID(c)
+	if _, banned := roomInfo.BannedUntil(uid, time.Now()); banned {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "BANNED_FROM_ROOM"})
+	}
+	if !roomInfo.CanView(uid) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "ROOM_ACCESS_DENIED"})
+	}
+	remoteAddr := c.IP()
+	// EventSource resends the last id it saw when it reconnects; last_seq
+	// serves clients that reconnect by hand, as with the socket.
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_seq")
+	}
+	lastSeq, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		conn := newEventConn(w)
+		client := roomhub.NewClient(conn, roomId, uid, remoteAddr)
+		resumed, err := roomhub.Attach(client, lastSeq, loadRoom)
+		if err != nil {
+			logger.Error("sse initial sync failed", "roomId", roomId, "uid", uid, "error", err)
+		}
+		logger.Info("sse client connected", "roomId", roomId, "uid", uid, "resumed", resumed)
+
+		defer func() {
+			roomhub.Unregister(client)
+			logger.Info("sse client disconnected", "roomId", roomId, "uid", uid)
+		}()
+
+		client.Send(roomhub.PresenceState(roomId))
+		if uid != "" && !roomInfo.CheckMember(uid) {
+			client.Send(roomhub.NeedToJoin(roomInfo))
+		}
+		conn.keepAlive(client)
+	})
+	return nil
+}
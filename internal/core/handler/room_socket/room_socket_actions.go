@@ -0,0 +1,400 @@
+package roomsocket
+
+import (
+	"errors"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomaccess "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_access"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	socketService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_socket"
+)
+
+func init() {
+	register("JOIN_ROOM", actionOptions{role: roleIdentified, failure: ErrJoinRoomFailed}, joinRoom)
+	register("LEAVE_ROOM", actionOptions{role: roleMember, failure: ErrLeaveRoomFailed, optionalPayload: true}, leaveRoom)
+	register("UPDATE_PROFILE", actionOptions{role: roleMember, failure: ErrUpdateProfileFailed}, updateProfile)
+	register("UPDATE_ESTIMATED_VALUE", actionOptions{role: roleMember, failure: ErrUpdateEstimatedValueFailed}, updateEstimatedValue)
+	register("REVEAL_CARDS", actionOptions{role: roleMember, failure: ErrRevealCardsFailed, optionalPayload: true}, revealCards)
+	register("NEXT_ROUND", actionOptions{role: roleMember, failure: ErrNextRoundFailed, optionalPayload: true}, nextRound)
+	register("REVOTE", actionOptions{role: roleOwner, failure: ErrRevoteFailed, optionalPayload: true}, revote)
+	register("SET_TICKET_ESTIMATION", actionOptions{role: roleMember, failure: ErrSetTicketEstimationFailed}, setTicketEstimation)
+	register("SET_TICKET_DETAILS", actionOptions{role: roleOwner, failure: ErrSetTicketDetailsFailed}, setTicketDetails)
+	register("ADD_NOTE", actionOptions{role: roleMember, failure: ErrAddNoteFailed}, addNote)
+	register("SET_TICKET_QUEUE", actionOptions{role: roleMember, failure: ErrSetTicketQueueFailed}, setTicketQueue)
+	register("SET_TICKET_QUEUE_WITH_ESTIMATION", actionOptions{role: roleMember, failure: ErrSetTicketQueueWithEstimationFailed}, setTicketQueueWithEstimation)
+	register("SET_FINAL_STORY_POINT", actionOptions{role: roleMember, failure: ErrSetFinalStoryPointFailed}, setFinalStoryPoint)
+	register("RENAME_ROOM", actionOptions{role: roleOwner, failure: ErrRenameRoomFailed}, renameRoom)
+	register("CHANGE_DECK", actionOptions{role: roleOwner, failure: ErrChangeDeckFailed}, changeDeck)
+	register("SET_PASSCODE", actionOptions{role: roleOwner, failure: ErrSetPasscodeFailed}, setPasscode)
+	register("SET_VOTING_MODE", actionOptions{role: roleOwner, failure: ErrSetVotingModeFailed}, setVotingMode)
+	register("SET_DIMENSIONS", actionOptions{role: roleOwner, failure: ErrSetDimensionsFailed}, setDimensions)
+	register("SET_DELPHI_ROUNDS", actionOptions{role: roleOwner, failure: ErrSetDelphiRoundsFailed}, setDelphiRounds)
+	register("START_ASYNC", actionOptions{role: roleOwner, failure: ErrStartAsyncFailed}, startAsync)
+	register("ASYNC_VOTE", actionOptions{role: roleMember, failure: ErrAsyncVoteFailed}, asyncVote)
+	register("END_ASYNC", actionOptions{role: roleOwner, failure: ErrEndAsyncFailed, optionalPayload: true}, endAsync)
+	register("DELETE_ROOM", actionOptions{role: roleOwner, failure: ErrDeleteRoomFailed, optionalPayload: true}, deleteRoom)
+	register("THROW_EMOJI", actionOptions{role: roleViewer, failure: ErrInternal}, throwEmoji)
+	register("SYNC", actionOptions{role: roleViewer, failure: ErrInternal, optionalPayload: true, socketOnly: true}, syncRoom)
+	register("PING", actionOptions{failure: ErrInternal, optionalPayload: true}, ping)
+}
+
+// joinRoom admits the sender, checking the passcode or invite of a private
+// room, and subscribes a socket that was waiting to be let in.
+func joinRoom(ctx *actionContext, p joinRoomPayload) error {
+	current, err := ctx.loadRoom()
+	if err != nil {
+		return loadError(err)
+	}
+	switch err := roomaccess.Admit(current, ctx.roomId, ctx.uid, ctx.remoteAddr, p.Passcode, p.Invite); err {
+	case nil:
+	case roomaccess.ErrTooManyAttempts:
+		return &actionError{code: ErrTooManyAttempts, err: err}
+	case roomaccess.ErrBanned:
+		return &actionError{code: ErrBannedFromRoom, err: err}
+	default:
+		return &actionError{code: ErrRoomAccessDenied, err: err}
+	}
+
+	roomInfo, err := socketService.JoinRoom(ctx.uid, p.Name, p.Profile, ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.MemberJoined(roomInfo, ctx.uid))
+	if ctx.attach != nil {
+		ctx.attach()
+	}
+	return nil
+}
+
+// leaveRoom takes the sender out of the room. Their connections keep
+// receiving a public room's events; a private room stops sending them and
+// asks them to join again.
+func leaveRoom(ctx *actionContext, _ noPayload) error {
+	roomInfo, err := socketService.LeaveRoom(ctx.uid, ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.MemberLeft(roomInfo, ctx.uid))
+	if !roomInfo.CanView(ctx.uid) {
+		roomhub.DetachUser(ctx.roomId, ctx.uid, roomhub.NeedToJoin(roomInfo))
+	}
+	return nil
+}
+
+func updateProfile(ctx *actionContext, p updateProfilePayload) error {
+	roomInfo, err := socketService.UpdateProfile(ctx.uid, p.Name, p.Profile, ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.MemberUpdated(roomInfo, ctx.uid))
+	return nil
+}
+
+// updateEstimatedValue votes with a single card, or on dimensions in a
+// multi-dimensional room.
+func updateEstimatedValue(ctx *actionContext, p votePayload) error {
+	var (
+		roomInfo domain.Room
+		err      error
+	)
+	if p.Dimensions != nil {
+		roomInfo, err = socketService.VoteDimensions(ctx.uid, p.Dimensions, p.Confidence, ctx.roomId)
+	} else {
+		roomInfo, err = socketService.UpdateEstimatedValue(ctx.uid, p.Value, p.Confidence, ctx.roomId)
+	}
+	if errors.Is(err, domain.ErrInvalidDimensionVote) || errors.Is(err, domain.ErrDimensionsRequired) {
+		return &actionError{code: ErrInvalidPayload, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.VoteCast(roomInfo, ctx.uid))
+	return nil
+}
+
+func revealCards(ctx *actionContext, _ noPayload) error {
+	roomInfo, err := socketService.RevealCards(ctx.uid, ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.CardsRevealed(roomInfo))
+	return nil
+}
+
+// nextRound starts a new round. With a ticket in the payload it re-votes
+// that ticket instead of taking the next one from the queue.
+func nextRound(ctx *actionContext, p nextRoundPayload) error {
+	var (
+		roomInfo domain.Room
+		err      error
+	)
+	if p.TicketEstimation != nil {
+		roomInfo, err = socketService.ResetRoomWithTicket(ctx.roomId, transformTicketToDomain(*p.TicketEstimation), transformQueueToDomain(p.TicketQueue))
+	} else {
+		roomInfo, err = socketService.ResetRoom(ctx.roomId)
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.RoundStarted(roomInfo))
+	return nil
+}
+
+// revote starts another round on the revealed ticket, keeping the earlier
+// ones in the room's Delphi rounds.
+func revote(ctx *actionContext, _ noPayload) error {
+	roomInfo, err := socketService.Revote(ctx.roomId)
+	switch {
+	case errors.Is(err, domain.ErrCardsNotRevealed):
+		return &actionError{code: ErrCardsNotRevealed, err: err}
+	case errors.Is(err, domain.ErrDelphiRoundLimit):
+		return &actionError{code: ErrRevoteLimitReached, err: err}
+	case err != nil:
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.RoundStarted(roomInfo))
+	return nil
+}
+
+func setTicketEstimation(ctx *actionContext, p setTicketEstimationPayload) error {
+	roomInfo, err := socketService.SetTicketEstimation(transformOptionalTicketToDomain(p.TicketEstimation), ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.TicketChanged(roomInfo))
+	return nil
+}
+
+// setTicketDetails edits a ticket's details. The queue event carries them
+// with the active ticket.
+func setTicketDetails(ctx *actionContext, p setTicketDetailsPayload) error {
+	roomInfo, err := socketService.SetTicketDetails(ctx.roomId, p.TicketKey, p.details().Sanitized())
+	if errors.Is(err, domain.ErrTicketNotFound) || errors.Is(err, domain.ErrNoActiveTicket) || errors.Is(err, domain.ErrTicketDetailsTooLarge) {
+		return &actionError{code: ErrInvalidPayload, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.QueueChanged(roomInfo))
+	return nil
+}
+
+func addNote(ctx *actionContext, p addNotePayload) error {
+	roomInfo, note, err := socketService.AddNote(ctx.uid, p.Text, ctx.roomId)
+	if errors.Is(err, domain.ErrNoActiveTicket) {
+		return &actionError{code: ErrInvalidPayload, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.NoteAdded(roomInfo, note))
+	return nil
+}
+
+func setTicketQueue(ctx *actionContext, p setTicketQueuePayload) error {
+	roomInfo, err := socketService.SetTicketQueue(transformQueueToDomain(p.TicketQueue), ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.QueueChanged(roomInfo))
+	return nil
+}
+
+func setTicketQueueWithEstimation(ctx *actionContext, p setTicketQueueWithEstimationPayload) error {
+	roomInfo, err := socketService.SetTicketQueueWithEstimation(transformQueueToDomain(p.TicketQueue), transformOptionalTicketToDomain(p.TicketEstimation), ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.QueueChanged(roomInfo))
+	return nil
+}
+
+func setFinalStoryPoint(ctx *actionContext, p estimatedPointPayload) error {
+	roomInfo, err := socketService.SetFinalStoryPoint(ctx.roomId, p.Value)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.FinalScoreSet(roomInfo))
+	return nil
+}
+
+func renameRoom(ctx *actionContext, p renameRoomPayload) error {
+	roomInfo, err := socketService.RenameRoom(ctx.roomId, p.Name)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, nil))
+	return nil
+}
+
+func changeDeck(ctx *actionContext, p changeDeckPayload) error {
+	policy := p.VotePolicy
+	if policy == "" {
+		policy = domain.DeckVotesClearInvalid
+	}
+	roomInfo, withdrawn, err := socketService.ChangeDeck(ctx.roomId, p.DeskConfig, policy)
+	if errors.Is(err, domain.ErrVotesNotOnDeck) {
+		return &actionError{code: ErrVotesNotOnDeck, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, withdrawn))
+	return nil
+}
+
+func setPasscode(ctx *actionContext, p setPasscodePayload) error {
+	roomInfo, err := socketService.SetPasscode(ctx.roomId, p.Passcode)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, nil))
+	// Outsiders who were watching the room while it was public see no more
+	// of it until they join with the passcode.
+	roomhub.DetachWhere(ctx.roomId, func(uid string) bool { return !roomInfo.CanView(uid) }, roomhub.NeedToJoin(roomInfo))
+	return nil
+}
+
+// setVotingMode switches anonymous voting. Leaving recordVotes out keeps
+// the room's current choice.
+func setVotingMode(ctx *actionContext, p setVotingModePayload) error {
+	recordVotes := ctx.room.RecordVotes
+	if p.RecordVotes != nil {
+		recordVotes = *p.RecordVotes
+	}
+	roomInfo, err := socketService.SetVotingMode(ctx.roomId, *p.AnonymousVoting, recordVotes)
+	if errors.Is(err, domain.ErrRoundInProgress) {
+		return &actionError{code: ErrRoundInProgress, err: err}
+	}
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, nil))
+	return nil
+}
+
+func setDimensions(ctx *actionContext, p setDimensionsPayload) error {
+	roomInfo, withdrawn, err := socketService.SetDimensions(ctx.roomId, transformDimensionsToDomain(p.Dimensions), transformScoreFormulaToDomain(p.ScoreFormula))
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, withdrawn))
+	return nil
+}
+
+func setDelphiRounds(ctx *actionContext, p setDelphiRoundsPayload) error {
+	roomInfo, err := socketService.SetDelphiMaxRounds(ctx.roomId, *p.DelphiMaxRounds)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.SettingsChanged(roomInfo, nil))
+	return nil
+}
+
+func startAsync(ctx *actionContext, p startAsyncPayload) error {
+	roomInfo, err := socketService.StartAsync(ctx.roomId, p.TicketKeys, *p.Deadline)
+	if err != nil {
+		return asyncError(err)
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.AsyncChanged(roomInfo, nil))
+	return nil
+}
+
+func asyncVote(ctx *actionContext, p asyncVotePayload) error {
+	roomInfo, rounds, err := socketService.AsyncVote(ctx.uid, p.TicketKey, p.Value, ctx.roomId)
+	if err != nil {
+		return asyncError(err)
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.AsyncChanged(roomInfo, rounds))
+	return nil
+}
+
+// endAsync reveals what is left of the async session before its deadline.
+func endAsync(ctx *actionContext, _ noPayload) error {
+	roomInfo, rounds, err := socketService.CloseAsync(ctx.roomId, false)
+	if err != nil {
+		return asyncError(err)
+	}
+	ctx.room = roomInfo
+	roomhub.Broadcast(ctx.roomId, roomhub.AsyncChanged(roomInfo, rounds))
+	return nil
+}
+
+func asyncError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrAsyncInProgress):
+		return &actionError{code: ErrAsyncInProgress, err: err}
+	case errors.Is(err, domain.ErrNoAsyncSession):
+		return &actionError{code: ErrNoAsyncSession, err: err}
+	case errors.Is(err, domain.ErrAsyncTicketRevealed):
+		return &actionError{code: ErrAsyncTicketRevealed, err: err}
+	case errors.Is(err, domain.ErrAsyncTicketNotFound), errors.Is(err, domain.ErrNoAsyncTickets), errors.Is(err, domain.ErrAsyncMultiDimensional):
+		return &actionError{code: ErrInvalidPayload, err: err}
+	}
+	return err
+}
+
+// deleteRoom deletes the room and disconnects everyone in it, the sender
+// included, so the sender's ACK is usually lost; ROOM_DELETED confirms it.
+func deleteRoom(ctx *actionContext, _ noPayload) error {
+	roomInfo, err := socketService.DeleteRoom(ctx.roomId)
+	if err != nil {
+		return err
+	}
+	ctx.room = roomInfo
+	roomhub.CloseDeletedRoom(ctx.roomId, "room deleted by owner")
+	return nil
+}
+
+// throwEmoji relays the throw to everyone else in the room. The sender's
+// client animates it locally.
+func throwEmoji(ctx *actionContext, p throwEmojiPayload) error {
+	roomhub.BroadcastToOthers(ctx.client, ctx.roomId, roomhub.Message{
+		Action: roomhub.ActionEmojiThrown,
+		Payload: emojiThrownPayload{
+			FromUserID:          ctx.uid,
+			Emoji:               p.Emoji,
+			TargetMemberID:      p.TargetMemberID,
+			TargetTableMemberID: p.TargetTableMemberID,
+			TargetPanelMemberID: p.TargetPanelMemberID,
+			TargetXRatio:        p.TargetXRatio,
+			TargetYRatio:        p.TargetYRatio,
+		},
+	})
+	return nil
+}
+
+// syncRoom resends the whole room after the client saw a version gap.
+func syncRoom(ctx *actionContext, _ noPayload) error {
+	if err := roomhub.SendSnapshot(ctx.client, ctx.loadRoom); err != nil {
+		return loadError(err)
+	}
+	return ctx.client.Send(roomhub.PresenceState(ctx.roomId))
+}
+
+// ping only marks the sender active, which the read loop does for every
+// message.
+func ping(*actionContext, noPayload) error {
+	return nil
+}
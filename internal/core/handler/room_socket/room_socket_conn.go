@@ -0,0 +1,62 @@
+package roomsocket
+
+import (
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+)
+
+const closeWriteTimeout = time.Second
+
+// socketConn adapts a WebSocket connection to roomhub.Conn, sending a close
+// frame with the reason before dropping the connection.
+type socketConn struct {
+	*websocket.Conn
+}
+
+func (s socketConn) Close(reason string) error {
+	// Control frame payloads are capped at 125 bytes, two of which hold the code.
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	_ = s.Conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
+		time.Now().Add(closeWriteTimeout))
+	return s.Conn.Close()
+}
+
+// watchPongs sets the read deadline and has each pong push it out, so a
+// connection that stops answering keepAlive's pings fails its next read and
+// is torn down. The pong handler runs on the reading goroutine, so this must
+// be called before reading starts.
+func watchPongs(c *websocket.Conn) {
+	extend := func() {
+		_ = c.SetReadDeadline(time.Now().Add(configs.Conf.PresenceOfflineAfter))
+	}
+	extend()
+	c.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+}
+
+// keepAlive pings the connection every WS_PING_INTERVAL until done is
+// closed. The tick also lets presence notice members going idle.
+// WriteControl may run alongside other writes.
+func keepAlive(c *websocket.Conn, client *roomhub.Client, done <-chan struct{}) {
+	ticker := time.NewTicker(configs.Conf.WSPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(closeWriteTimeout)); err != nil {
+				return
+			}
+			roomhub.RefreshPresence(client.RoomID, client.UID)
+		}
+	}
+}
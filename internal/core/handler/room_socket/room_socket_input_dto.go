@@ -1,13 +1,94 @@
 package roomsocket
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+)
+
+// maxEmojiBytes leaves room for ZWJ sequences and skin-tone modifiers.
+const maxEmojiBytes = 64
+
+// maxPasscodeBytes is bcrypt's input limit; longer passcodes would be
+// silently truncated.
+const maxPasscodeBytes = 72
+
 type joinRoomPayload struct {
 	Name    string `json:"name"`
 	Profile string `json:"profile"`
+	// Passcode or Invite admit the sender to a private room.
+	Passcode string `json:"passcode,omitempty"`
+	Invite   string `json:"invite,omitempty"`
+}
+
+func (p joinRoomPayload) Validate() error {
+	if err := validateProfile(p.Name, p.Profile); err != nil {
+		return err
+	}
+	if len(p.Passcode) > maxPasscodeBytes || len(p.Invite) > 1024 {
+		return errors.New("passcode or invite too long")
+	}
+	return nil
 }
+
+// updateProfilePayload replaces the sender's name and picture.
+type updateProfilePayload struct {
+	Name    string `json:"name"`
+	Profile string `json:"profile"`
+}
+
+func (p updateProfilePayload) Validate() error {
+	return validateProfile(p.Name, p.Profile)
+}
+
+// validateProfile bounds the fields stored on the member (security: prevent
+// resource exhaustion and XSS).
+func validateProfile(name, profile string) error {
+	if len(name) == 0 || len(name) > 100 {
+		return errors.New("name must be 1-100 characters")
+	}
+	if len(profile) > 500 {
+		return errors.New("profile URL too long (max 500)")
+	}
+	return nil
+}
+
 type estimatedPointPayload struct {
 	Value string `json:"value"`
 }
 
+type votePayload struct {
+	Value string `json:"value"`
+	// Dimensions votes in a multi-dimensional room instead of Value, as
+	// dimension key to card; an empty card withdraws that vote.
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	// Confidence is 1 to 5, or 0 to take it back; left out, the previous
+	// one is kept. Withdrawing the vote withdraws it too.
+	Confidence *int `json:"confidence,omitempty"`
+}
+
+func (p votePayload) Validate() error {
+	if p.Confidence != nil && (*p.Confidence < 0 || *p.Confidence > domain.MaxConfidence) {
+		return fmt.Errorf("confidence must be between 0 and %d", domain.MaxConfidence)
+	}
+	if p.Dimensions != nil && p.Value != "" {
+		return errors.New("send either value or dimensions")
+	}
+	if len(p.Value) > 50 || len(p.Dimensions) > 5 {
+		return errors.New("vote too large")
+	}
+	for key, card := range p.Dimensions {
+		if len(key) > 32 || len(card) > 50 {
+			return errors.New("vote too large")
+		}
+	}
+	return nil
+}
+
 type setTicketEstimationPayload struct {
 	TicketEstimation *ticketEstimationDTO `json:"ticketEstimation"`
 }
@@ -23,17 +104,102 @@ type ticketEstimationDTO struct {
 	StoryPointsField string  `json:"storyPointsField"`
 	AvgScore         float64 `json:"avgScore,omitempty"`
 	FinalScore       string  `json:"finalScore,omitempty"`
+	NeedsRefinement  bool    `json:"needsRefinement,omitempty"`
+	NeedsDiscussion  bool    `json:"needsDiscussion,omitempty"`
+
+	Description        string   `json:"description,omitempty"`
+	AcceptanceCriteria []string `json:"acceptanceCriteria,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
+	Links              []string `json:"links,omitempty"`
+}
+
+func (t ticketEstimationDTO) details() domain.TicketDetails {
+	return domain.TicketDetails{
+		Description:        t.Description,
+		AcceptanceCriteria: t.AcceptanceCriteria,
+		Labels:             t.Labels,
+		Links:              t.Links,
+	}
+}
+
+// validateTickets checks the details of every ticket given, and the queue's
+// length and the details' total size.
+func validateTickets(active *ticketEstimationDTO, queue []ticketEstimationDTO) error {
+	if active != nil {
+		if err := active.details().Validate(); err != nil {
+			return err
+		}
+	}
+	for _, t := range queue {
+		if err := t.details().Validate(); err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return domain.ValidateTickets(transformOptionalTicketToDomain(active), transformQueueToDomain(queue))
+}
+
+func (p setTicketEstimationPayload) Validate() error {
+	return validateTickets(p.TicketEstimation, nil)
+}
+
+type addNotePayload struct {
+	Text string `json:"text"`
+}
+
+func (p addNotePayload) Validate() error {
+	if strings.TrimSpace(p.Text) == "" {
+		return errors.New("text is required")
+	}
+	if len(p.Text) > domain.MaxNoteLength {
+		return fmt.Errorf("text exceeds %d characters", domain.MaxNoteLength)
+	}
+	return nil
+}
+
+// setTicketDetailsPayload replaces the details of the queued or active
+// ticket with the given Jira key or name, or of the active ticket without
+// one.
+type setTicketDetailsPayload struct {
+	TicketKey          string   `json:"ticketKey,omitempty"`
+	Description        string   `json:"description"`
+	AcceptanceCriteria []string `json:"acceptanceCriteria"`
+	Labels             []string `json:"labels"`
+	Links              []string `json:"links"`
+}
+
+func (p setTicketDetailsPayload) details() domain.TicketDetails {
+	return domain.TicketDetails{
+		Description:        p.Description,
+		AcceptanceCriteria: p.AcceptanceCriteria,
+		Labels:             p.Labels,
+		Links:              p.Links,
+	}
+}
+
+func (p setTicketDetailsPayload) Validate() error {
+	if len(p.TicketKey) > 255 {
+		return errors.New("ticketKey exceeds 255 characters")
+	}
+	return p.details().Validate()
 }
 
 type setTicketQueuePayload struct {
 	TicketQueue []ticketEstimationDTO `json:"ticketQueue"`
 }
 
+func (p setTicketQueuePayload) Validate() error {
+	return validateTickets(nil, p.TicketQueue)
+}
+
 type setTicketQueueWithEstimationPayload struct {
 	TicketQueue      []ticketEstimationDTO `json:"ticketQueue"`
 	TicketEstimation *ticketEstimationDTO  `json:"ticketEstimation"`
 }
 
+func (p setTicketQueueWithEstimationPayload) Validate() error {
+	return validateTickets(p.TicketEstimation, p.TicketQueue)
+}
+
 // nextRoundPayload is optional — when provided, explicit ticket/queue overrides
 // Restart()'s auto-selection (used for re-voting a specific ticket).
 type nextRoundPayload struct {
@@ -41,21 +207,174 @@ type nextRoundPayload struct {
 	TicketQueue      []ticketEstimationDTO `json:"ticketQueue"`
 }
 
+func (p nextRoundPayload) Validate() error {
+	return validateTickets(p.TicketEstimation, p.TicketQueue)
+}
+
 type throwEmojiPayload struct {
-	Emoji                string   `json:"emoji"`
-	TargetMemberID       *string  `json:"target_member_id,omitempty"`
-	TargetTableMemberID  *string  `json:"target_table_member_id,omitempty"`
-	TargetPanelMemberID  *string  `json:"target_panel_member_id,omitempty"`
-	TargetXRatio         *float64 `json:"target_x_ratio,omitempty"`
-	TargetYRatio         *float64 `json:"target_y_ratio,omitempty"`
+	Emoji               string   `json:"emoji"`
+	TargetMemberID      *string  `json:"target_member_id,omitempty"`
+	TargetTableMemberID *string  `json:"target_table_member_id,omitempty"`
+	TargetPanelMemberID *string  `json:"target_panel_member_id,omitempty"`
+	TargetXRatio        *float64 `json:"target_x_ratio,omitempty"`
+	TargetYRatio        *float64 `json:"target_y_ratio,omitempty"`
+}
+
+func (p throwEmojiPayload) Validate() error {
+	if len(p.Emoji) == 0 || len(p.Emoji) > maxEmojiBytes {
+		return errors.New("emoji must be 1-64 bytes")
+	}
+	return nil
+}
+
+type renameRoomPayload struct {
+	Name string `json:"name"`
+}
+
+// Validate applies the same bound as room creation.
+func (p renameRoomPayload) Validate() error {
+	if len(p.Name) == 0 || len(p.Name) > 100 {
+		return errors.New("name must be 1-100 characters")
+	}
+	return nil
+}
+
+type changeDeckPayload struct {
+	DeskConfig string `json:"deskConfig"`
+	// VotePolicy is one of the domain.DeckVotes* policies, clear_invalid
+	// when empty.
+	VotePolicy string `json:"votePolicy,omitempty"`
+}
+
+func (p changeDeckPayload) Validate() error {
+	if len(p.DeskConfig) > 500 {
+		return errors.New("deskConfig exceeds 500 characters")
+	}
+	for _, card := range domain.DeckCards(p.DeskConfig) {
+		if card == "" {
+			return errors.New("deskConfig must be a comma-separated list of cards")
+		}
+	}
+	switch p.VotePolicy {
+	case "", domain.DeckVotesClearInvalid, domain.DeckVotesClearAll, domain.DeckVotesReject:
+		return nil
+	default:
+		return fmt.Errorf("unknown votePolicy %q", p.VotePolicy)
+	}
+}
+
+type setPasscodePayload struct {
+	// Passcode makes the room private; empty makes it public again.
+	Passcode string `json:"passcode"`
+}
+
+func (p setPasscodePayload) Validate() error {
+	if p.Passcode != "" && (len(p.Passcode) < 4 || len(p.Passcode) > maxPasscodeBytes) {
+		return errors.New("passcode must be 4-72 bytes, or empty to remove it")
+	}
+	return nil
+}
+
+type setVotingModePayload struct {
+	AnonymousVoting *bool `json:"anonymousVoting"`
+	// RecordVotes keeps anonymous votes in the round history for the owner.
+	RecordVotes *bool `json:"recordVotes,omitempty"`
+}
+
+func (p setVotingModePayload) Validate() error {
+	if p.AnonymousVoting == nil {
+		return errors.New("anonymousVoting is required")
+	}
+	return nil
+}
+
+type setDimensionsPayload struct {
+	// Dimensions empty, with no ScoreFormula, turns the room back to
+	// single-value voting.
+	Dimensions   []dimensionDTO   `json:"dimensions"`
+	ScoreFormula *scoreFormulaDTO `json:"scoreFormula,omitempty"`
 }
 
+type dimensionDTO struct {
+	Key    string  `json:"key"`
+	Name   string  `json:"name"`
+	Deck   string  `json:"deck"`
+	Weight float64 `json:"weight,omitempty"`
+}
+
+type scoreFormulaDTO struct {
+	Kind   string            `json:"kind"`
+	Matrix map[string]string `json:"matrix,omitempty"`
+}
+
+func (p setDimensionsPayload) Validate() error {
+	return domain.ValidateDimensions(transformDimensionsToDomain(p.Dimensions), transformScoreFormulaToDomain(p.ScoreFormula))
+}
+
+type setDelphiRoundsPayload struct {
+	// DelphiMaxRounds caps the rounds each ticket gets, 0 for no cap.
+	DelphiMaxRounds *int `json:"delphiMaxRounds"`
+}
+
+func (p setDelphiRoundsPayload) Validate() error {
+	if p.DelphiMaxRounds == nil {
+		return errors.New("delphiMaxRounds is required")
+	}
+	if *p.DelphiMaxRounds < 0 || *p.DelphiMaxRounds > domain.MaxDelphiRounds {
+		return fmt.Errorf("delphiMaxRounds must be between 0 and %d", domain.MaxDelphiRounds)
+	}
+	return nil
+}
+
+// startAsyncPayload opens an async session on the queued tickets with the
+// given Jira keys or names, or on every unscored one when none are given.
+type startAsyncPayload struct {
+	TicketKeys []string   `json:"ticketKeys,omitempty"`
+	Deadline   *time.Time `json:"deadline"`
+}
+
+func (p startAsyncPayload) Validate() error {
+	if p.Deadline == nil {
+		return errors.New("deadline is required")
+	}
+	now := time.Now()
+	if !p.Deadline.After(now) {
+		return errors.New("deadline must be in the future")
+	}
+	if p.Deadline.After(now.Add(configs.Conf.AsyncMaxDuration)) {
+		return fmt.Errorf("deadline must be within %s", configs.Conf.AsyncMaxDuration)
+	}
+	if len(p.TicketKeys) > domain.MaxAsyncTickets {
+		return fmt.Errorf("at most %d tickets", domain.MaxAsyncTickets)
+	}
+	return nil
+}
+
+type asyncVotePayload struct {
+	TicketKey string `json:"ticketKey"`
+	// Value empty withdraws the vote.
+	Value string `json:"value"`
+}
+
+func (p asyncVotePayload) Validate() error {
+	if p.TicketKey == "" {
+		return errors.New("ticketKey is required")
+	}
+	if len(p.Value) > 50 {
+		return errors.New("vote too large")
+	}
+	return nil
+}
+
+// noPayload is the payload of actions that take none.
+type noPayload struct{}
+
 type emojiThrownPayload struct {
-	FromUserID           string   `json:"from_user_id"`
-	Emoji                string   `json:"emoji"`
-	TargetMemberID       *string  `json:"target_member_id,omitempty"`
-	TargetTableMemberID  *string  `json:"target_table_member_id,omitempty"`
-	TargetPanelMemberID  *string  `json:"target_panel_member_id,omitempty"`
-	TargetXRatio         *float64 `json:"target_x_ratio,omitempty"`
-	TargetYRatio         *float64 `json:"target_y_ratio,omitempty"`
+	FromUserID          string   `json:"from_user_id"`
+	Emoji               string   `json:"emoji"`
+	TargetMemberID      *string  `json:"target_member_id,omitempty"`
+	TargetTableMemberID *string  `json:"target_table_member_id,omitempty"`
+	TargetPanelMemberID *string  `json:"target_panel_member_id,omitempty"`
+	TargetXRatio        *float64 `json:"target_x_ratio,omitempty"`
+	TargetYRatio        *float64 `json:"target_y_ratio,omitempty"`
 }
@@ -0,0 +1,252 @@
+package roomsocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	socketService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_socket"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// role is what the sender must be before an action runs. Every role but
+// roleAny and roleViewer needs a sender the cookie identified; an anonymous
+// connection only watches.
+type role int
+
+const (
+	// roleAny allows any connection, including anonymous ones.
+	roleAny role = iota
+	// roleIdentified allows anyone a cookie identified, joined or not.
+	roleIdentified
+	// roleViewer allows anyone who may see the room: anybody for a public
+	// room, members and the owner for a private one.
+	roleViewer
+	// roleMember requires the sender to be a member of the room.
+	roleMember
+	// roleOwner requires the sender to own the room, member or not.
+	roleOwner
+)
+
+// actionOptions describe an action apart from its payload type and handler.
+type actionOptions struct {
+	role role
+	// failure is the code sent when the handler fails for any reason other
+	// than an actionError.
+	failure ErrorCode
+	// optionalPayload lets the action be sent without a payload, in which
+	// case the handler gets the zero value.
+	optionalPayload bool
+	// socketOnly keeps the action off the REST API, for actions that answer
+	// on the sender's connection.
+	socketOnly bool
+}
+
+// actionContext is what a handler knows about the connection and sender.
+// client is nil when the action arrives over REST.
+type actionContext struct {
+	client     *roomhub.Client
+	roomId     string
+	uid        string
+	remoteAddr string
+	loadRoom   func() (domain.Room, error)
+	// attach subscribes the socket to the room once it may see it, for
+	// sockets that connected to a private room before joining. It is nil
+	// over REST.
+	attach func()
+
+	// room is loaded for all but roleAny actions, to check the role.
+	// Handlers that change the room replace it with the result. Usecases
+	// reload the room, so they take the uid rather than a position in
+	// room.Members, which a concurrent leave may shift.
+	room domain.Room
+}
+
+// actionError lets a handler pick the NACK code itself, for failures that
+// are the client's fault.
+type actionError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *actionError) Error() string {
+	if e.err == nil {
+		return string(e.code)
+	}
+	return fmt.Sprintf("%s: %v", e.code, e.err)
+}
+
+// payloadValidator is implemented by payloads with rules beyond their shape.
+type payloadValidator interface {
+	Validate() error
+}
+
+type registeredAction struct {
+	options     actionOptions
+	payloadType reflect.Type
+	// check decodes and validates a payload without running the action.
+	check func(raw json.RawMessage) error
+	run   func(ctx *actionContext, raw json.RawMessage) error
+}
+
+var actions = make(map[string]registeredAction)
+
+// register adds an action whose payload decodes into P. Registering the same
+// name twice is a programming error.
+func register[P any](name string, options actionOptions, handle func(ctx *actionContext, payload P) error) {
+	if _, exists := actions[name]; exists {
+		panic("roomsocket: action registered twice: " + name)
+	}
+	decode := func(raw json.RawMessage) (P, error) {
+		var payload P
+		if len(raw) == 0 || string(raw) == "null" {
+			if !options.optionalPayload {
+				return payload, &actionError{code: ErrInvalidPayload, err: errors.New("payload is required")}
+			}
+		} else if err := json.Unmarshal(raw, &payload); err != nil {
+			return payload, &actionError{code: ErrInvalidPayload, err: err}
+		}
+		if v, ok := any(payload).(payloadValidator); ok {
+			if err := v.Validate(); err != nil {
+				return payload, &actionError{code: ErrInvalidPayload, err: err}
+			}
+		}
+		return payload, nil
+	}
+	actions[name] = registeredAction{
+		options:     options,
+		payloadType: reflect.TypeOf((*P)(nil)).Elem(),
+		check: func(raw json.RawMessage) error {
+			_, err := decode(raw)
+			return err
+		},
+		run: func(ctx *actionContext, raw json.RawMessage) error {
+			payload, err := decode(raw)
+			if err != nil {
+				return err
+			}
+			if options.role == roleAny {
+				return authorizeAndHandle(ctx, options.role, payload, handle)
+			}
+			// Whatever the sender may do depends on the room as it is once
+			// the changes before theirs are published.
+			roomhub.Serialize(ctx.roomId, func() {
+				err = authorizeAndHandle(ctx, options.role, payload, handle)
+			})
+			return err
+		},
+	}
+}
+
+func authorizeAndHandle[P any](ctx *actionContext, r role, payload P, handle func(ctx *actionContext, payload P) error) error {
+	if err := authorize(ctx, r); err != nil {
+		return err
+	}
+	err := handle(ctx, payload)
+	if errors.Is(err, socketService.ErrMemberNotFound) {
+		return &actionError{code: ErrNotFoundUser, err: err}
+	}
+	return loadError(err)
+}
+
+// authorize loads the room for roles that depend on it and checks the
+// sender has the role.
+func authorize(ctx *actionContext, r role) error {
+	if ctx.uid == "" && r != roleAny && r != roleViewer {
+		return &actionError{code: ErrNotAuthenticated}
+	}
+	if r == roleAny || r == roleIdentified {
+		return nil
+	}
+	room, err := ctx.loadRoom()
+	if err != nil {
+		return loadError(err)
+	}
+	ctx.room = room
+	switch {
+	case r == roleMember && socketService.FindMemberIndex(ctx.room.Members, ctx.uid) == -1:
+		return &actionError{code: ErrNotFoundUser}
+	case r == roleOwner && !ctx.room.IsOwner(ctx.uid):
+		return &actionError{code: ErrNotRoomOwner}
+	case r == roleViewer && !ctx.room.CanView(ctx.uid):
+		return &actionError{code: ErrRoomAccessDenied}
+	}
+	return nil
+}
+
+// loadError turns a failure to load the room into the error to answer with:
+// ROOM_NOT_FOUND once the room was deleted.
+func loadError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, roomService.ErrRoomNotFound) {
+		return &actionError{code: ErrRoomNotFound, err: err}
+	}
+	return err
+}
+
+// registeredActionNames lists every inbound action, sorted.
+func registeredActionNames() []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dispatch runs one inbound message and answers it with an ACK or NACK.
+func dispatch(ctx *actionContext, message messageAction) {
+	action, ok := actions[message.Action]
+	if !ok {
+		nack(ctx.client, message.RequestID, ErrUnknownAction, fmt.Errorf("unknown action %q", message.Action))
+		return
+	}
+
+	err := action.run(ctx, message.Payload)
+	if err == nil {
+		ack(ctx.client, message.RequestID)
+		return
+	}
+
+	var ae *actionError
+	if errors.As(err, &ae) {
+		logger.Warn("ws action rejected", "action", message.Action, "roomId", ctx.roomId, "uid", ctx.uid, "error", err)
+		nack(ctx.client, message.RequestID, ae.code, ae.err)
+		// The room was deleted while the socket was open, perhaps through
+		// another instance that could not close it.
+		if ae.code == ErrRoomNotFound {
+			_ = ctx.client.Close("room not found")
+		}
+		return
+	}
+	logger.Error("ws action failed", "action", message.Action, "roomId", ctx.roomId, "uid", ctx.uid, "error", err)
+	nack(ctx.client, message.RequestID, action.options.failure, nil)
+}
+
+// perform runs an action without a socket, for the REST API. It fails with
+// the code a NACK would carry.
+func perform(ctx *actionContext, name string, payload json.RawMessage) *actionError {
+	action, ok := actions[name]
+	if !ok || action.options.socketOnly {
+		return &actionError{code: ErrUnknownAction, err: fmt.Errorf("unknown action %q", name)}
+	}
+
+	err := action.run(ctx, payload)
+	if err == nil {
+		return nil
+	}
+	var ae *actionError
+	if errors.As(err, &ae) {
+		logger.Warn("rest action rejected", "action", name, "roomId", ctx.roomId, "uid", ctx.uid, "error", err)
+		return ae
+	}
+	logger.Error("rest action failed", "action", name, "roomId", ctx.roomId, "uid", ctx.uid, "error", err)
+	return &actionError{code: action.options.failure}
+}
@@ -0,0 +1,128 @@
+package roomsocket
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("test")
+	os.Exit(m.Run())
+}
+
+type recordingConn struct {
+	sent   []interface{}
+	closed bool
+}
+
+func (c *recordingConn) WriteJSON(v interface{}) error {
+	c.sent = append(c.sent, v)
+	return nil
+}
+
+func (c *recordingConn) Close(string) error {
+	c.closed = true
+	return nil
+}
+
+// dispatchRaw runs one raw client frame and returns the single reply sent.
+func dispatchRaw(t *testing.T, frame string) reply {
+	t.Helper()
+	var message messageAction
+	if err := json.Unmarshal([]byte(frame), &message); err != nil {
+		t.Fatal(err)
+	}
+	conn := &recordingConn{}
+	ctx := actionContext{client: roomhub.NewClient(conn, t.Name(), "alice", ""), roomId: t.Name(), uid: "alice"}
+	dispatch(&ctx, message)
+
+	if len(conn.sent) != 1 {
+		t.Fatalf("expected one reply, got %+v", conn.sent)
+	}
+	r, ok := conn.sent[0].(reply)
+	if !ok {
+		t.Fatalf("expected a reply, got %+v", conn.sent[0])
+	}
+	return r
+}
+
+func TestDispatch_AckCarriesRequestID(t *testing.T) {
+	r := dispatchRaw(t, `{"action":"PING","request_id":"r1"}`)
+	if r.Action != actionAck || r.RequestID != "r1" {
+		t.Errorf("expected ACK r1, got %+v", r)
+	}
+}
+
+func TestDispatch_UnknownAction(t *testing.T) {
+	r := dispatchRaw(t, `{"action":"LAUNCH_ROCKET","request_id":"r2"}`)
+	if r.Action != actionNack || r.Error != ErrUnknownAction || r.RequestID != "r2" {
+		t.Errorf("expected UNKNOWN_ACTION NACK, got %+v", r)
+	}
+}
+
+func TestDispatch_InvalidPayloads(t *testing.T) {
+	long := strings.Repeat("x", 101)
+	cases := map[string]string{
+		"missing payload":   `{"action":"JOIN_ROOM"}`,
+		"wrong shape":       `{"action":"JOIN_ROOM","payload":"alice"}`,
+		"failed validation": `{"action":"JOIN_ROOM","payload":{"name":"` + long + `"}}`,
+		"empty emoji":       `{"action":"THROW_EMOJI","payload":{"emoji":""}}`,
+		"script link":       `{"action":"SET_TICKET_ESTIMATION","payload":{"ticketEstimation":{"name":"T-1","links":["javascript:alert(1)"]}}}`,
+		"overlong queue":    `{"action":"SET_TICKET_QUEUE","payload":{"ticketQueue":[` + strings.Repeat(`{"name":"T"},`, domain.MaxTicketQueue) + `{"name":"T"}]}}`,
+	}
+	for name, frame := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := dispatchRaw(t, frame)
+			if r.Action != actionNack || r.Error != ErrInvalidPayload || r.Details == "" {
+				t.Errorf("expected INVALID_PAYLOAD NACK with details, got %+v", r)
+			}
+		})
+	}
+}
+
+func TestDispatch_DeletedRoomNacksAndCloses(t *testing.T) {
+	conn := &recordingConn{}
+	ctx := actionContext{
+		client:   roomhub.NewClient(conn, t.Name(), "alice", ""),
+		roomId:   t.Name(),
+		uid:      "alice",
+		loadRoom: func() (domain.Room, error) { return domain.Room{}, roomService.ErrRoomNotFound },
+	}
+
+	dispatch(&ctx, messageAction{Action: "REVEAL_CARDS"})
+
+	if r, ok := conn.sent[0].(reply); !ok || r.Action != actionNack || r.Error != ErrRoomNotFound {
+		t.Errorf("expected ROOM_NOT_FOUND NACK, got %+v", conn.sent)
+	}
+	if !conn.closed {
+		t.Error("expected the socket closed")
+	}
+}
+
+func TestDispatch_AnonymousSenderOnlyWatches(t *testing.T) {
+	for _, frame := range []string{
+		`{"action":"JOIN_ROOM","payload":{"name":"Mallory"}}`,
+		`{"action":"DELETE_ROOM"}`,
+		`{"action":"REVEAL_CARDS"}`,
+	} {
+		conn := &recordingConn{}
+		ctx := actionContext{client: roomhub.NewClient(conn, t.Name(), "", ""), roomId: t.Name()}
+		var message messageAction
+		if err := json.Unmarshal([]byte(frame), &message); err != nil {
+			t.Fatal(err)
+		}
+
+		dispatch(&ctx, message)
+
+		if r := conn.sent[0].(reply); r.Action != actionNack || r.Error != ErrNotAuthenticated {
+			t.Errorf("%s: expected NOT_AUTHENTICATED NACK, got %+v", message.Action, r)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package roomsocket
+
+// ErrorCode is a stable, machine-readable reason an action was rejected.
+// Codes are part of the protocol: add new ones, never rename or reuse them.
+type ErrorCode string
+
+const (
+	// Connection-level errors, sent before the socket is closed.
+	ErrRoomNotFound ErrorCode = "ROOM_NOT_FOUND"
+	ErrInternal     ErrorCode = "INTERNAL_ERROR"
+
+	// The frame or its payload could not be used. Retrying unchanged fails again.
+	ErrInvalidMessageFormat ErrorCode = "INVALID_MESSAGE_FORMAT"
+	ErrUnknownAction        ErrorCode = "UNKNOWN_ACTION"
+	ErrInvalidPayload       ErrorCode = "INVALID_PAYLOAD"
+	ErrNotAuthenticated     ErrorCode = "NOT_AUTHENTICATED"
+	ErrNotFoundUser         ErrorCode = "NOT_FOUND_USER"
+	ErrNotRoomOwner         ErrorCode = "NOT_ROOM_OWNER"
+	ErrRoomAccessDenied     ErrorCode = "ROOM_ACCESS_DENIED"
+	ErrTooManyAttempts      ErrorCode = "TOO_MANY_ATTEMPTS"
+	ErrBannedFromRoom       ErrorCode = "BANNED_FROM_ROOM"
+	ErrVotesNotOnDeck       ErrorCode = "VOTES_NOT_ON_DECK"
+	ErrRoundInProgress      ErrorCode = "ROUND_IN_PROGRESS"
+	ErrCardsNotRevealed     ErrorCode = "CARDS_NOT_REVEALED"
+	ErrRevoteLimitReached   ErrorCode = "REVOTE_LIMIT_REACHED"
+	ErrAsyncInProgress      ErrorCode = "ASYNC_IN_PROGRESS"
+	ErrNoAsyncSession       ErrorCode = "NO_ASYNC_SESSION"
+	ErrAsyncTicketRevealed  ErrorCode = "ASYNC_TICKET_REVEALED"
+
+	// The action was valid but could not be applied. Safe to retry.
+	ErrJoinRoomFailed                     ErrorCode = "JOIN_ROOM_FAILED"
+	ErrLeaveRoomFailed                    ErrorCode = "LEAVE_ROOM_FAILED"
+	ErrUpdateProfileFailed                ErrorCode = "UPDATE_PROFILE_FAILED"
+	ErrUpdateEstimatedValueFailed         ErrorCode = "UPDATE_ESTIMATED_VALUE_FAILED"
+	ErrRevealCardsFailed                  ErrorCode = "REVEAL_CARDS_FAILED"
+	ErrNextRoundFailed                    ErrorCode = "NEXT_ROUND_FAILED"
+	ErrSetTicketEstimationFailed          ErrorCode = "SET_TICKET_ESTIMATION_FAILED"
+	ErrSetTicketQueueFailed               ErrorCode = "SET_TICKET_QUEUE_FAILED"
+	ErrSetTicketQueueWithEstimationFailed ErrorCode = "SET_TICKET_QUEUE_WITH_ESTIMATION_FAILED"
+	ErrSetFinalStoryPointFailed           ErrorCode = "SET_FINAL_STORY_POINT_FAILED"
+	ErrRenameRoomFailed                   ErrorCode = "RENAME_ROOM_FAILED"
+	ErrChangeDeckFailed                   ErrorCode = "CHANGE_DECK_FAILED"
+	ErrDeleteRoomFailed                   ErrorCode = "DELETE_ROOM_FAILED"
+	ErrSetPasscodeFailed                  ErrorCode = "SET_PASSCODE_FAILED"
+	ErrSetVotingModeFailed                ErrorCode = "SET_VOTING_MODE_FAILED"
+	ErrSetDimensionsFailed                ErrorCode = "SET_DIMENSIONS_FAILED"
+	ErrRevoteFailed                       ErrorCode = "REVOTE_FAILED"
+	ErrSetDelphiRoundsFailed              ErrorCode = "SET_DELPHI_ROUNDS_FAILED"
+	ErrStartAsyncFailed                   ErrorCode = "START_ASYNC_FAILED"
+	ErrAsyncVoteFailed                    ErrorCode = "ASYNC_VOTE_FAILED"
+	ErrEndAsyncFailed                     ErrorCode = "END_ASYNC_FAILED"
+	ErrSetTicketDetailsFailed             ErrorCode = "SET_TICKET_DETAILS_FAILED"
+	ErrAddNoteFailed                      ErrorCode = "ADD_NOTE_FAILED"
+)
+
+const (
+	actionAck  = "ACK"
+	actionNack = "NACK"
+)
+
+// reply answers one inbound action. Error and Details sit at the top level
+// so clients that only look for "error" keep working.
+type reply struct {
+	Action    string    `json:"action"`
+	RequestID string    `json:"request_id,omitempty"`
+	Error     ErrorCode `json:"error,omitempty"`
+	Details   string    `json:"details,omitempty"`
+}
+
+// sender is the part of roomhub.Client replies need.
+type sender interface {
+	Send(message interface{}) error
+}
+
+// ack confirms an action. Clients that sent no request_id are not waiting
+// for one, so nothing is sent to them.
+func ack(client sender, requestID string) {
+	if requestID == "" {
+		return
+	}
+	_ = client.Send(reply{Action: actionAck, RequestID: requestID})
+}
+
+// nack rejects an action. It is always sent; err, when given, becomes the
+// details.
+func nack(client sender, requestID string, code ErrorCode, err error) {
+	r := reply{Action: actionNack, RequestID: requestID, Error: code}
+	if err != nil {
+		r.Details = err.Error()
+	}
+	_ = client.Send(r)
+}
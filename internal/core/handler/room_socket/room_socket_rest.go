@@ -0,0 +1,191 @@
+package roomsocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	participantauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/participant"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	socketService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_socket"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// ActionHandler serves a socket action over REST, for clients that cannot
+// keep a WebSocket open. The request body is the action's payload and the
+// route must sit behind participantauth.RequireParticipant. The action is
+// validated, authorized and broadcast exactly as it is over the socket, and
+// the response carries the updated room.
+func ActionHandler(action string) fiber.Handler {
+	mustBeRESTAction(action)
+	return func(c *fiber.Ctx) error {
+		return performREST(c, []restCall{{action: action, payload: c.Body()}})
+	}
+}
+
+// settingsField is a field PATCH /rooms/:roomId accepts. Its value becomes
+// the action's payload field of the same name, along with any of the with
+// fields the request also carries.
+type settingsField struct {
+	action string
+	with   []string
+}
+
+var settingsFields = map[string]settingsField{
+	"anonymousVoting":  {action: "SET_VOTING_MODE", with: []string{"recordVotes"}},
+	"delphiMaxRounds":  {action: "SET_DELPHI_ROUNDS"},
+	"deskConfig":       {action: "CHANGE_DECK", with: []string{"votePolicy"}},
+	"dimensions":       {action: "SET_DIMENSIONS", with: []string{"scoreFormula"}},
+	"name":             {action: "RENAME_ROOM"},
+	"passcode":         {action: "SET_PASSCODE"},
+	"ticketEstimation": {action: "SET_TICKET_ESTIMATION"},
+}
+
+// SettingsHandler applies a partial update of the room's settings. Each field
+// present runs its own action, in a fixed order, so sockets see the same
+// events they would had the changes been made there. Every field's payload
+// and permission is checked before any is applied; only a conflict with the
+// room's state can stop a later field, and then the error carries the room
+// as the earlier ones left it.
+func SettingsHandler(c *fiber.Ctx) error {
+	calls, ae := settingsCalls(c.Body())
+	if ae != nil {
+		return c.Status(httpStatus(ae.code)).JSON(errorBody(ae))
+	}
+	return performREST(c, calls)
+}
+
+type restCall struct {
+	action  string
+	payload json.RawMessage
+}
+
+func settingsCalls(body []byte) ([]restCall, *actionError) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, &actionError{code: ErrInvalidPayload, err: err}
+	}
+
+	var names []string
+	companions := map[string]bool{}
+	for name := range fields {
+		if field, ok := settingsFields[name]; ok {
+			names = append(names, name)
+			for _, with := range field.with {
+				companions[with] = true
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, &actionError{code: ErrInvalidPayload, err: errors.New("no settings to change")}
+	}
+	for name := range fields {
+		if _, ok := settingsFields[name]; !ok && !companions[name] {
+			return nil, &actionError{code: ErrInvalidPayload, err: fmt.Errorf("unknown setting %q", name)}
+		}
+	}
+	sort.Strings(names)
+
+	calls := make([]restCall, 0, len(names))
+	for _, name := range names {
+		field := settingsFields[name]
+		payload := map[string]json.RawMessage{name: fields[name]}
+		for _, with := range field.with {
+			if value, ok := fields[with]; ok {
+				payload[with] = value
+			}
+		}
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, &actionError{code: ErrInvalidPayload, err: err}
+		}
+		if err := actions[field.action].check(raw); err != nil {
+			ae := err.(*actionError)
+			return nil, &actionError{code: ae.code, err: fmt.Errorf("%s: %w", name, ae.err)}
+		}
+		calls = append(calls, restCall{action: field.action, payload: raw})
+	}
+	return calls, nil
+}
+
+// performREST runs calls in order on behalf of the caller and answers with
+// the room after the last one, or with the first failure. With several calls
+// the caller's role for each is checked before any runs, and a failure after
+// some were applied also carries the room as they left it.
+func performREST(c *fiber.Ctx, calls []restCall) error {
+	roomId := c.Params("roomId")
+	uid := participantauth.UID(c)
+	if _, err := roomService.FindRoom(roomId); errors.Is(err, roomService.ErrRoomNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": ErrRoomNotFound})
+	}
+
+	if roomhub.MarkUserActive(roomId, uid) {
+		if err := socketService.TouchMember(uid, roomId); err != nil {
+			logger.Error("persist last active failed", "roomId", roomId, "uid", uid, "error", err)
+		}
+	}
+
+	ctx := actionContext{
+		roomId:     roomId,
+		uid:        uid,
+		remoteAddr: c.IP(),
+		loadRoom:   func() (domain.Room, error) { return roomService.FindRoom(roomId) },
+	}
+	if len(calls) > 1 {
+		for _, call := range calls {
+			if err := authorize(&ctx, actions[call.action].options.role); err != nil {
+				ae := err.(*actionError)
+				return c.Status(httpStatus(ae.code)).JSON(errorBody(ae))
+			}
+		}
+	}
+	for i, call := range calls {
+		if ae := perform(&ctx, call.action, call.payload); ae != nil {
+			body := errorBody(ae)
+			if i > 0 {
+				body["data"] = ctx.room.ViewFor(ctx.uid)
+			}
+			return c.Status(httpStatus(ae.code)).JSON(body)
+		}
+	}
+	return c.JSON(fiber.Map{"data": ctx.room.ViewFor(ctx.uid)})
+}
+
+func mustBeRESTAction(action string) {
+	if a, ok := actions[action]; !ok || a.options.socketOnly {
+		panic("roomsocket: no REST action " + action)
+	}
+}
+
+func errorBody(ae *actionError) fiber.Map {
+	body := fiber.Map{"error": ae.code}
+	if ae.err != nil {
+		body["details"] = ae.err.Error()
+	}
+	return body
+}
+
+// httpStatus maps an error code to the status the REST API answers with.
+func httpStatus(code ErrorCode) int {
+	switch code {
+	case ErrInvalidPayload, ErrInvalidMessageFormat:
+		return fiber.StatusBadRequest
+	case ErrNotFoundUser, ErrNotRoomOwner, ErrRoomAccessDenied, ErrBannedFromRoom:
+		return fiber.StatusForbidden
+	case ErrNotAuthenticated:
+		return fiber.StatusUnauthorized
+	case ErrTooManyAttempts:
+		return fiber.StatusTooManyRequests
+	case ErrVotesNotOnDeck, ErrRoundInProgress, ErrCardsNotRevealed, ErrRevoteLimitReached,
+		ErrAsyncInProgress, ErrNoAsyncSession, ErrAsyncTicketRevealed:
+		return fiber.StatusConflict
+	case ErrRoomNotFound, ErrUnknownAction:
+		return fiber.StatusNotFound
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,55 @@
+package roomsocket
+
+import (
+	"testing"
+)
+
+func TestSettingsCalls_MapsFieldsToActions(t *testing.T) {
+	calls, ae := settingsCalls([]byte(`{"ticketEstimation":null}`))
+	if ae != nil {
+		t.Fatal(ae)
+	}
+	if len(calls) != 1 || calls[0].action != "SET_TICKET_ESTIMATION" || string(calls[0].payload) != `{"ticketEstimation":null}` {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
+
+func TestSettingsCalls_SendsCompanionFieldsAlong(t *testing.T) {
+	calls, ae := settingsCalls([]byte(`{"votePolicy":"reject","name":"Sprint 12","deskConfig":"1,2,3"}`))
+	if ae != nil {
+		t.Fatal(ae)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected two calls, got %+v", calls)
+	}
+	if calls[0].action != "CHANGE_DECK" || string(calls[0].payload) != `{"deskConfig":"1,2,3","votePolicy":"reject"}` {
+		t.Errorf("unexpected deck call %s %s", calls[0].action, calls[0].payload)
+	}
+	if calls[1].action != "RENAME_ROOM" || string(calls[1].payload) != `{"name":"Sprint 12"}` {
+		t.Errorf("unexpected rename call %s %s", calls[1].action, calls[1].payload)
+	}
+}
+
+func TestSettingsCalls_RejectsBadBodies(t *testing.T) {
+	for name, body := range map[string]string{
+		"not an object":  `[]`,
+		"empty":          `{}`,
+		"unknown field":  `{"status":"VOTING"}`,
+		"companion only": `{"votePolicy":"reject"}`,
+		"one bad field":  `{"name":"Sprint 12","delphiMaxRounds":99}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, ae := settingsCalls([]byte(body)); ae == nil || ae.code != ErrInvalidPayload {
+				t.Errorf("expected INVALID_PAYLOAD, got %v", ae)
+			}
+		})
+	}
+}
+
+func TestSettingsFields_AreRESTActions(t *testing.T) {
+	for name, field := range settingsFields {
+		if a, ok := actions[field.action]; !ok || a.options.socketOnly {
+			t.Errorf("setting %s maps to %s, which is not a REST action", name, field.action)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package roomsocket
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	asyncAPIPath = "../../../../asyncapi.yaml"
+	roomHubDir   = "../../usecase/room_hub"
+)
+
+// outboundPayloads maps every server action to its payload type. nil means
+// the action carries no payload.
+var outboundPayloads = map[string]reflect.Type{
+	roomhub.ActionUpdateRoom:      reflect.TypeOf(domain.Room{}),
+	roomhub.ActionNeedToJoin:      reflect.TypeOf(roomhub.NeedToJoinPayload{}),
+	roomhub.ActionMemberJoined:    reflect.TypeOf(roomhub.MemberJoinedPayload{}),
+	roomhub.ActionMemberUpdated:   reflect.TypeOf(roomhub.MemberJoinedPayload{}),
+	roomhub.ActionMemberLeft:      reflect.TypeOf(roomhub.MemberLeftPayload{}),
+	roomhub.ActionMemberKicked:    reflect.TypeOf(roomhub.MemberKickedPayload{}),
+	roomhub.ActionVoteCast:        reflect.TypeOf(roomhub.VoteCastPayload{}),
+	roomhub.ActionCardsRevealed:   reflect.TypeOf(roomhub.RoundStatePayload{}),
+	roomhub.ActionRoundStarted:    reflect.TypeOf(roomhub.RoundStatePayload{}),
+	roomhub.ActionFinalScoreSet:   reflect.TypeOf(roomhub.RoundStatePayload{}),
+	roomhub.ActionTicketChanged:   reflect.TypeOf(roomhub.TicketChangedPayload{}),
+	roomhub.ActionQueueChanged:    reflect.TypeOf(roomhub.QueueChangedPayload{}),
+	roomhub.ActionSettingsChanged: reflect.TypeOf(roomhub.SettingsChangedPayload{}),
+	roomhub.ActionAsyncChanged:    reflect.TypeOf(roomhub.AsyncChangedPayload{}),
+	roomhub.ActionNoteAdded:       reflect.TypeOf(roomhub.NoteAddedPayload{}),
+	roomhub.ActionRoomDeleted:     nil,
+	roomhub.ActionPresenceChanged: reflect.TypeOf(roomhub.PresenceChangedPayload{}),
+	roomhub.ActionPresenceState:   reflect.TypeOf(roomhub.PresenceStatePayload{}),
+	roomhub.ActionEmojiThrown:     reflect.TypeOf(emojiThrownPayload{}),
+}
+
+// sharedSchemas are component schemas that mirror a Go type field for field.
+var sharedSchemas = map[string]reflect.Type{
+	"Room":             reflect.TypeOf(domain.Room{}),
+	"Member":           reflect.TypeOf(domain.Member{}),
+	"Round":            reflect.TypeOf(domain.Round{}),
+	"Dimension":        reflect.TypeOf(dimensionDTO{}),
+	"ScoreFormula":     reflect.TypeOf(scoreFormulaDTO{}),
+	"DimensionStats":   reflect.TypeOf(domain.DimensionStats{}),
+	"ConfidenceStats":  reflect.TypeOf(domain.ConfidenceStats{}),
+	"DelphiRound":      reflect.TypeOf(domain.DelphiRound{}),
+	"AsyncSession":     reflect.TypeOf(domain.AsyncSession{}),
+	"AsyncTicket":      reflect.TypeOf(domain.AsyncTicket{}),
+	"TicketNote":       reflect.TypeOf(domain.TicketNote{}),
+	"TicketEstimation": reflect.TypeOf(ticketEstimationDTO{}),
+	"Presence":         reflect.TypeOf(roomhub.PresenceChangedPayload{}),
+}
+
+type asyncAPISpec struct {
+	Channels map[string]struct {
+		Publish   asyncAPIOperation `yaml:"publish"`
+		Subscribe asyncAPIOperation `yaml:"subscribe"`
+	} `yaml:"channels"`
+	Components struct {
+		Messages map[string]struct {
+			Name    string       `yaml:"name"`
+			Payload schemaObject `yaml:"payload"`
+		} `yaml:"messages"`
+		Schemas map[string]schemaObject `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type asyncAPIOperation struct {
+	Message struct {
+		OneOf []schemaObject `yaml:"oneOf"`
+	} `yaml:"message"`
+}
+
+type schemaObject struct {
+	Ref        string                  `yaml:"$ref"`
+	Properties map[string]schemaObject `yaml:"properties"`
+}
+
+func loadAsyncAPI(t *testing.T) asyncAPISpec {
+	t.Helper()
+	data, err := os.ReadFile(asyncAPIPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec asyncAPISpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func (s asyncAPISpec) resolve(t *testing.T, schema schemaObject) schemaObject {
+	t.Helper()
+	if schema.Ref == "" {
+		return schema
+	}
+	name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+	resolved, ok := s.Components.Schemas[name]
+	if !ok {
+		t.Fatalf("unresolved $ref %s", schema.Ref)
+	}
+	return resolved
+}
+
+// jsonFields lists the JSON names of a struct's fields.
+func jsonFields(typ reflect.Type) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func propertyNames(schema schemaObject) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func assertSameFields(t *testing.T, what string, typ reflect.Type, schema schemaObject) {
+	t.Helper()
+	want, got := jsonFields(typ), propertyNames(schema)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("%s: spec has %v, %s has %v", what, got, typ, want)
+	}
+}
+
+// assertMessage checks one message: its envelope against envelope, and its
+// payload property against payloadType.
+func assertMessage(t *testing.T, spec asyncAPISpec, action string, envelope, payloadType reflect.Type) {
+	t.Helper()
+	message, ok := spec.Components.Messages[action]
+	if !ok {
+		t.Errorf("asyncapi.yaml has no message for %s", action)
+		return
+	}
+	if message.Name != action {
+		t.Errorf("message %s is named %q", action, message.Name)
+	}
+
+	for name := range message.Payload.Properties {
+		if !contains(jsonFields(envelope), name) {
+			t.Errorf("%s: envelope field %q is not in %s", action, name, envelope)
+		}
+	}
+	payload, hasPayload := message.Payload.Properties["payload"]
+	switch {
+	case payloadType == nil || payloadType == reflect.TypeOf(noPayload{}):
+		if hasPayload {
+			t.Errorf("%s takes no payload but the spec describes one", action)
+		}
+	case !hasPayload:
+		t.Errorf("%s: spec describes no payload, expected %s", action, payloadType)
+	default:
+		assertSameFields(t, action+" payload", payloadType, spec.resolve(t, payload))
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func channelMessages(t *testing.T, spec asyncAPISpec, publish bool) []string {
+	t.Helper()
+	var names []string
+	for _, channel := range spec.Channels {
+		operation := channel.Subscribe
+		if publish {
+			operation = channel.Publish
+		}
+		for _, ref := range operation.Message.OneOf {
+			names = append(names, strings.TrimPrefix(ref.Ref, "#/components/messages/"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestAsyncAPI_InboundActions(t *testing.T) {
+	spec := loadAsyncAPI(t)
+
+	envelope := reflect.TypeOf(messageAction{})
+	for _, name := range registeredActionNames() {
+		assertMessage(t, spec, name, envelope, actions[name].payloadType)
+	}
+
+	if published := channelMessages(t, spec, true); !reflect.DeepEqual(published, registeredActionNames()) {
+		t.Errorf("publish operation lists %v, registered actions are %v", published, registeredActionNames())
+	}
+}
+
+func TestAsyncAPI_OutboundActions(t *testing.T) {
+	spec := loadAsyncAPI(t)
+
+	var want []string
+	envelope := reflect.TypeOf(roomhub.Message{})
+	for name, payloadType := range outboundPayloads {
+		assertMessage(t, spec, name, envelope, payloadType)
+		want = append(want, name)
+	}
+
+	replyType := reflect.TypeOf(reply{})
+	for _, name := range []string{actionAck, actionNack} {
+		assertMessage(t, spec, name, replyType, nil)
+		want = append(want, name)
+	}
+
+	sort.Strings(want)
+	if subscribed := channelMessages(t, spec, false); !reflect.DeepEqual(subscribed, want) {
+		t.Errorf("subscribe operation lists %v, server sends %v", subscribed, want)
+	}
+}
+
+// roomHubActions returns the values of the exported Action* constants of
+// roomhub, every action the server can send.
+func roomHubActions(t *testing.T) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, roomHubDir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					value := spec.(*ast.ValueSpec)
+					for i, ident := range value.Names {
+						if !ident.IsExported() || !strings.HasPrefix(ident.Name, "Action") || i >= len(value.Values) {
+							continue
+						}
+						lit, ok := value.Values[i].(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							continue
+						}
+						name, err := strconv.Unquote(lit.Value)
+						if err != nil {
+							t.Fatal(err)
+						}
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOutboundPayloads_CoverEveryRoomHubAction(t *testing.T) {
+	actions := roomHubActions(t)
+	if len(actions) == 0 {
+		t.Fatal("found no Action constants in roomhub")
+	}
+	for _, name := range actions {
+		if _, ok := outboundPayloads[name]; !ok {
+			t.Errorf("roomhub sends %s, which outboundPayloads does not list", name)
+		}
+	}
+}
+
+func TestAsyncAPI_SharedSchemas(t *testing.T) {
+	spec := loadAsyncAPI(t)
+	for name, typ := range sharedSchemas {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("asyncapi.yaml has no %s schema", name)
+			continue
+		}
+		assertSameFields(t, name, typ, schema)
+	}
+}
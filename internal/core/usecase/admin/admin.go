@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/timer"
+	repo "github.com/raksitnongbua/planning-poker-service/internal/repository/room"
+)
+
+var ErrRoomNotFound = errors.New("room not found")
+
+const (
+	StateAny    = ""
+	StateActive = "active"
+	StateIdle   = "idle"
+)
+
+type RoomFilter struct {
+	// State splits rooms on UpdatedAt relative to IdleAfter.
+	State      string
+	IdleAfter  time.Duration
+	MinMembers int
+	// MaxMembers is ignored when negative.
+	MaxMembers int
+	Limit      int
+}
+
+type RoomOverview struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Status           string    `json:"status"`
+	MemberCount      int       `json:"member_count"`
+	ConnectedSockets int       `json:"connected_sockets"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type RoomDetail struct {
+	ID      string               `json:"id"`
+	Room    domain.Room          `json:"room"`
+	Sockets []roomhub.ClientInfo `json:"sockets"`
+}
+
+func ListRooms(filter RoomFilter) ([]RoomOverview, error) {
+	var updatedAfter, updatedBefore time.Time
+	threshold := timer.GetTimeNow().Add(-filter.IdleAfter)
+	switch filter.State {
+	case StateActive:
+		updatedAfter = threshold
+	case StateIdle:
+		updatedBefore = threshold
+	}
+
+	keep := func(room domain.Room) bool {
+		count := len(room.Members)
+		if count < filter.MinMembers {
+			return false
+		}
+		return filter.MaxMembers < 0 || count <= filter.MaxMembers
+	}
+
+	records, err := repo.ListRooms(updatedAfter, updatedBefore, keep, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := roomhub.ConnectionCounts()
+	overviews := make([]RoomOverview, 0, len(records))
+	for _, r := range records {
+		overviews = append(overviews, RoomOverview{
+			ID:               r.ID,
+			Name:             r.Room.Name,
+			Status:           r.Room.Status,
+			MemberCount:      len(r.Room.Members),
+			ConnectedSockets: counts[r.ID],
+			CreatedAt:        r.Room.CreatedAt,
+			UpdatedAt:        r.Room.UpdatedAt,
+		})
+	}
+	return overviews, nil
+}
+
+func GetRoom(roomId string) (RoomDetail, error) {
+	room, found, err := repo.FindRoom(roomId)
+	if err != nil {
+		return RoomDetail{}, err
+	}
+	if !found {
+		return RoomDetail{}, ErrRoomNotFound
+	}
+	return RoomDetail{
+		ID:      roomId,
+		Room:    room,
+		Sockets: roomhub.Clients(roomId),
+	}, nil
+}
+
+// CloseRoomSockets disconnects everyone currently connected to the room on
+// this instance and returns how many connections were closed.
+func CloseRoomSockets(roomId, reason string) int {
+	return roomhub.CloseRoom(roomId, reason)
+}
+
+// DeleteRoom deletes the room, optionally archiving it first, and closes any
+// sockets still connected to it after sending them ROOM_DELETED.
+func DeleteRoom(roomId string, archive bool) (int, error) {
+	found, err := repo.DeleteRoom(roomId, archive)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, ErrRoomNotFound
+	}
+	return roomhub.CloseDeletedRoom(roomId, "room deleted by administrator"), nil
+}
+
+// RoomsJoinedBy returns every room whose EverJoinedMemberIDs contains userId.
+func RoomsJoinedBy(userId string) ([]domain.RoomRecord, error) {
+	return repo.QueryRoomsEverJoinedBy(userId)
+}
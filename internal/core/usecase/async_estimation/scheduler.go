@@ -0,0 +1,89 @@
+package asyncestimation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	idgenerator "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/id_generator"
+	roomhub "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_hub"
+	socketService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_socket"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/timer"
+	leaseRepo "github.com/raksitnongbua/planning-poker-service/internal/repository/lease"
+	repo "github.com/raksitnongbua/planning-poker-service/internal/repository/room"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+const leaseName = "async-deadlines"
+
+// StartScheduler closes async sessions past their deadline every
+// ASYNC_CHECK_INTERVAL until ctx is cancelled. Like the cleanup scheduler,
+// only the replica holding the lease runs a check.
+func StartScheduler(ctx context.Context) {
+	interval := configs.Conf.AsyncCheckInterval
+	holder := schedulerHolderID()
+	logger.Info("async deadline scheduler started", "interval", interval, "holder", holder)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := leaseRepo.Release(leaseName, holder); err != nil {
+				logger.Warn("failed to release async deadline lease", "error", err)
+			}
+			logger.Info("async deadline scheduler stopped")
+			return
+		case <-ticker.C:
+			runScheduled(holder, interval)
+		}
+	}
+}
+
+func runScheduled(holder string, interval time.Duration) {
+	acquired, err := leaseRepo.TryAcquire(leaseName, holder, interval)
+	if err != nil {
+		logger.Error("failed to acquire async deadline lease", "error", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	ids, err := repo.QueryAsyncDue(timer.GetTimeNow())
+	if err != nil {
+		logger.Error("failed to query due async sessions", "error", err)
+		return
+	}
+	for _, roomId := range ids {
+		closeDue(roomId)
+	}
+}
+
+// closeDue reveals and closes the room's async session if its deadline has
+// passed, and tells the room.
+func closeDue(roomId string) {
+	roomhub.Serialize(roomId, func() {
+		roomInfo, rounds, err := socketService.CloseAsync(roomId, true)
+		if errors.Is(err, domain.ErrNoAsyncSession) || errors.Is(err, repo.ErrRoomNotFound) {
+			return
+		}
+		if err != nil {
+			logger.Error("failed to close async session", "roomId", roomId, "error", err)
+			return
+		}
+		logger.Info("async session closed at deadline", "roomId", roomId, "revealed", len(rounds))
+		roomhub.Broadcast(roomId, roomhub.AsyncChanged(roomInfo, rounds))
+	})
+}
+
+func schedulerHolderID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return host + "-" + idgenerator.GenerateUUID()
+}
@@ -0,0 +1,37 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+)
+
+var archiveFileMu sync.Mutex
+
+// appendToArchiveFile writes one JSON line per room. It runs before the
+// rooms are deleted, so a crash can only duplicate lines, never lose a room.
+func appendToArchiveFile(path string, rooms []domain.RoomRecord, archivedAt time.Time) error {
+	archiveFileMu.Lock()
+	defer archiveFileMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, r := range rooms {
+		if err := enc.Encode(domain.NewArchivedRoom(r.ID, r.Room, archivedAt)); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
@@ -0,0 +1,113 @@
+package cleanup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/timer"
+	repo "github.com/raksitnongbua/planning-poker-service/internal/repository/room"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+type Options struct {
+	Retention   time.Duration
+	BatchSize   int
+	Archive     string
+	ArchiveFile string
+	DryRun      bool
+}
+
+// OptionsFromConfig returns the cleanup options configured for this service.
+func OptionsFromConfig() Options {
+	return Options{
+		Retention:   configs.Conf.RoomRetention,
+		BatchSize:   configs.Conf.CleanupBatchSize,
+		Archive:     configs.Conf.CleanupArchive,
+		ArchiveFile: configs.Conf.CleanupArchiveFile,
+		DryRun:      configs.Conf.CleanupDryRun,
+	}
+}
+
+// Run finds rooms idle for longer than opts.Retention and deletes them in
+// batches, archiving each batch first when an archive mode is set. In dry-run
+// mode it only reports what would be deleted.
+func Run(opts Options) (domain.CleanupResult, error) {
+	now := timer.GetTimeNow()
+	threshold := now.Add(-opts.Retention)
+	retentionDays := int(opts.Retention.Hours() / 24)
+
+	expired, err := repo.QueryExpiredRooms(threshold)
+	if err != nil {
+		return domain.CleanupResult{}, err
+	}
+
+	if opts.DryRun {
+		rooms := make([]domain.DeletedRoom, 0, len(expired))
+		for _, r := range expired {
+			rooms = append(rooms, toDeletedRoom(r))
+		}
+		return domain.CleanupResult{
+			Message:   fmt.Sprintf("Dry run: %d room(s) inactive for more than %d days would be deleted", len(rooms), retentionDays),
+			DryRun:    true,
+			Rooms:     rooms,
+			CleanedAt: now,
+		}, nil
+	}
+
+	result := domain.CleanupResult{Rooms: []domain.DeletedRoom{}}
+	for start := 0; start < len(expired); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(expired) {
+			end = len(expired)
+		}
+		batch := expired[start:end]
+
+		if opts.Archive == configs.ArchiveFile {
+			if err := appendToArchiveFile(opts.ArchiveFile, batch, now); err != nil {
+				return result, err
+			}
+		}
+
+		ids := make([]string, 0, len(batch))
+		for _, r := range batch {
+			ids = append(ids, r.ID)
+		}
+		deleted, err := repo.DeleteExpiredRooms(ids, threshold, opts.Archive == configs.ArchiveCollection)
+		if err != nil {
+			return result, err
+		}
+
+		deletedSet := make(map[string]bool, len(deleted))
+		for _, id := range deleted {
+			deletedSet[id] = true
+		}
+		for _, r := range batch {
+			if deletedSet[r.ID] {
+				result.Rooms = append(result.Rooms, toDeletedRoom(r))
+			}
+		}
+	}
+
+	result.Deleted = len(result.Rooms)
+	if opts.Archive != configs.ArchiveNone {
+		result.Archived = result.Deleted
+	}
+	result.Message = fmt.Sprintf("Successfully deleted %d expired room(s) inactive for more than %d days", result.Deleted, retentionDays)
+	if result.Deleted == 0 {
+		result.Message = "No expired rooms found"
+	}
+	result.CleanedAt = timer.GetTimeNow()
+
+	logger.Info("expired room cleanup finished", "deleted", result.Deleted, "archive", opts.Archive)
+	return result, nil
+}
+
+func toDeletedRoom(r domain.RoomRecord) domain.DeletedRoom {
+	return domain.DeletedRoom{
+		ID:        r.ID,
+		Name:      r.Room.Name,
+		UpdatedAt: r.Room.UpdatedAt,
+	}
+}
@@ -0,0 +1,69 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	idgenerator "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/id_generator"
+	leaseRepo "github.com/raksitnongbua/planning-poker-service/internal/repository/lease"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+const leaseName = "expired-room-cleanup"
+
+// StartScheduler runs the expired-room cleanup every CLEANUP_INTERVAL until
+// ctx is cancelled. Replicas compete for a Firestore lease that lives for one
+// interval, so only the current holder runs the cleanup.
+func StartScheduler(ctx context.Context) {
+	if !configs.Conf.CleanupEnabled {
+		logger.Info("expired room cleanup scheduler disabled")
+		return
+	}
+
+	interval := configs.Conf.CleanupInterval
+	holder := schedulerHolderID()
+	logger.Info("expired room cleanup scheduler started", "interval", interval, "holder", holder)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := leaseRepo.Release(leaseName, holder); err != nil {
+				logger.Warn("failed to release cleanup lease", "error", err)
+			}
+			logger.Info("expired room cleanup scheduler stopped")
+			return
+		case <-ticker.C:
+			runScheduled(holder, interval)
+		}
+	}
+}
+
+func runScheduled(holder string, interval time.Duration) {
+	acquired, err := leaseRepo.TryAcquire(leaseName, holder, interval)
+	if err != nil {
+		logger.Error("failed to acquire cleanup lease", "error", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	result, err := Run(OptionsFromConfig())
+	if err != nil {
+		logger.Error("scheduled expired room cleanup failed", "error", err)
+		return
+	}
+	logger.Info("scheduled expired room cleanup", "message", result.Message, "dry_run", result.DryRun)
+}
+
+func schedulerHolderID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return host + "-" + idgenerator.GenerateUUID()
+}
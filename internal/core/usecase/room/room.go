@@ -18,8 +18,20 @@ func GetRoomInfo(roomId string) domain.Room {
 	return repo.GetRoomInfo(roomId)
 }
 
-func IsRoomExists(roomId string) bool {
-	return repo.RoomExists(roomId)
+// ErrRoomNotFound is returned by FindRoom for a room that does not exist,
+// such as one deleted while its sockets were open.
+var ErrRoomNotFound = repo.ErrRoomNotFound
+
+// FindRoom loads the room, failing with ErrRoomNotFound when there is none.
+func FindRoom(roomId string) (domain.Room, error) {
+	roomInfo, found, err := repo.FindRoom(roomId)
+	if err != nil {
+		return domain.Room{}, err
+	}
+	if !found {
+		return domain.Room{}, ErrRoomNotFound
+	}
+	return roomInfo, nil
 }
 
 func GetResendRooms(id string) (rooms []map[string]interface{}, err error) {
@@ -27,24 +39,53 @@ func GetResendRooms(id string) (rooms []map[string]interface{}, err error) {
 	return rooms, err
 }
 
-func CleanupExpiredRooms() (domain.CleanupResult, error) {
-	return repo.DeleteExpiredRooms()
+var ErrMemberNotFound = errors.New("member not found")
+
+// KickMember removes the member from the room, withdrawing their vote. When
+// banFor is positive they are also banned for that long, on behalf of
+// bannedBy; a user who already left can still be banned.
+func KickMember(roomId, memberID, bannedBy string, banFor time.Duration) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.KickMemberFields, func(roomInfo *domain.Room) error {
+		now := time.Now()
+		kicked := roomInfo.KickMember(memberID, now)
+		if !kicked && banFor <= 0 {
+			return ErrMemberNotFound
+		}
+		if kicked {
+			roomInfo.UpdateResult()
+		}
+		if banFor > 0 {
+			roomInfo.Ban(memberID, bannedBy, now, now.Add(banFor))
+		}
+		return nil
+	})
 }
 
-func KickMember(roomId, memberID string) (domain.Room, error) {
-	roomInfo := GetRoomInfo(roomId)
-	if !roomInfo.KickMember(memberID, time.Now()) {
-		return domain.Room{}, errors.New("member not found")
+// LiftBan lets a banned user back into the room. Returns false if they were
+// not banned.
+func LiftBan(roomId, userID string) (bool, error) {
+	err := repo.UpdateBans(roomId, func(roomInfo *domain.Room) error {
+		if !roomInfo.LiftBan(userID) {
+			return errNotBanned
+		}
+		return nil
+	})
+	if errors.Is(err, errNotBanned) {
+		return false, nil
 	}
-	if err := repo.KickMember(roomId, roomInfo); err != nil {
-		return domain.Room{}, err
+	if err != nil {
+		return false, err
 	}
-	return roomInfo, nil
+	return true, nil
 }
 
-func CreateNewRoom(roomName, deskConfig string) (string, error) {
+// errNotBanned leaves the ban list unwritten when there is no ban to lift.
+var errNotBanned = errors.New("not banned")
+
+func CreateNewRoom(roomName, deskConfig, ownerID string) (string, error) {
 	roomId := idgenerator.GenerateUniqueRoomID()
 	room := domain.NewRoom(roomName, roomId, deskConfig)
+	room.OwnerID = ownerID
 
 	err := repo.CreateNewRoom(roomId, room)
 
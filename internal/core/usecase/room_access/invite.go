@@ -0,0 +1,99 @@
+package roomaccess
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+)
+
+var (
+	ErrInvitesDisabled = errors.New("invites are disabled")
+	errInvalidInvite   = errors.New("invalid invite")
+	errExpiredInvite   = errors.New("invite expired")
+)
+
+// Invite is a signed token that admits its bearer to one private room until
+// it expires or the room's passcode changes.
+type Invite struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// inviteClaims is the signed part of a token. Passcode binds the token to the
+// passcode it was issued under, so changing or clearing the passcode revokes
+// every outstanding invite.
+type inviteClaims struct {
+	RoomID    string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+	Passcode  string `json:"p"`
+}
+
+// CreateInvite signs an invite to the room lasting ttl, capped at INVITE_TTL.
+// A ttl of zero means INVITE_TTL.
+func CreateInvite(roomId, passcodeHash string, ttl time.Duration) (Invite, error) {
+	secret := configs.Conf.InviteSecret
+	if secret == "" {
+		return Invite{}, ErrInvitesDisabled
+	}
+	if ttl <= 0 || ttl > configs.Conf.InviteTTL {
+		ttl = configs.Conf.InviteTTL
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	claims, err := json.Marshal(inviteClaims{
+		RoomID:    roomId,
+		ExpiresAt: expiresAt.Unix(),
+		Passcode:  passcodeFingerprint(passcodeHash),
+	})
+	if err != nil {
+		return Invite{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	return Invite{Token: payload + "." + sign(secret, payload), ExpiresAt: expiresAt}, nil
+}
+
+func verifyInvite(token, roomId, passcodeHash string) error {
+	secret := configs.Conf.InviteSecret
+	if secret == "" {
+		return ErrInvitesDisabled
+	}
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(secret, payload))) {
+		return errInvalidInvite
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return errInvalidInvite
+	}
+	var claims inviteClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return errInvalidInvite
+	}
+	if claims.RoomID != roomId || claims.Passcode != passcodeFingerprint(passcodeHash) {
+		return errInvalidInvite
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return errExpiredInvite
+	}
+	return nil
+}
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// passcodeFingerprint identifies a passcode hash without putting any of it
+// in the token.
+func passcodeFingerprint(passcodeHash string) string {
+	sum := sha256.Sum256([]byte(passcodeHash))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
@@ -0,0 +1,58 @@
+package roomaccess
+
+import (
+	"errors"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrAccessDenied    = errors.New("wrong passcode or invalid invite")
+	ErrTooManyAttempts = errors.New("too many failed attempts, try again later")
+	ErrBanned          = errors.New("banned from the room")
+)
+
+// HashPasscode hashes a room passcode for storage. An empty passcode hashes
+// to "", which makes the room public.
+func HashPasscode(passcode string) (string, error) {
+	if passcode == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(passcode), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Admit decides whether uid, connecting from remoteAddr, may join the room.
+// Banned users never are. Public rooms, current members and the owner are
+// always admitted. Anyone else needs the passcode or a valid invite, and is
+// refused outright after JOIN_ATTEMPTS_MAX failures from the same address
+// within JOIN_ATTEMPTS_WINDOW.
+func Admit(room domain.Room, roomId, uid, remoteAddr, passcode, invite string) error {
+	if _, banned := room.BannedUntil(uid, time.Now()); banned {
+		return ErrBanned
+	}
+	if room.CanView(uid) {
+		return nil
+	}
+
+	key := attemptKey{roomId: roomId, remoteAddr: remoteAddr}
+	if !attempts.allowed(key) {
+		return ErrTooManyAttempts
+	}
+	if invite != "" && verifyInvite(invite, roomId, room.PasscodeHash) == nil {
+		attempts.reset(key)
+		return nil
+	}
+	if passcode != "" && room.PasscodeHash != "" &&
+		bcrypt.CompareHashAndPassword([]byte(room.PasscodeHash), []byte(passcode)) == nil {
+		attempts.reset(key)
+		return nil
+	}
+	attempts.fail(key)
+	return ErrAccessDenied
+}
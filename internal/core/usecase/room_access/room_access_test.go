@@ -0,0 +1,134 @@
+package roomaccess
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+)
+
+func setAccessConfig(t *testing.T) {
+	t.Helper()
+	saved := configs.Conf
+	t.Cleanup(func() { configs.Conf = saved })
+	configs.Conf.InviteSecret = strings.Repeat("s", 32)
+	configs.Conf.InviteTTL = time.Hour
+	configs.Conf.JoinAttemptsMax = 3
+	configs.Conf.JoinAttemptsWindow = time.Minute
+}
+
+func privateRoom(t *testing.T, passcode string) domain.Room {
+	t.Helper()
+	hash, err := HashPasscode(passcode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := domain.Room{OwnerID: "owner", Members: []domain.Member{{ID: "member"}}}
+	room.SetPasscode(hash, time.Now())
+	return room
+}
+
+func TestAdmit_PasscodeAndMembers(t *testing.T) {
+	setAccessConfig(t)
+	room := privateRoom(t, "hunter22")
+
+	if err := Admit(room, t.Name(), "member", "ip", "", ""); err != nil {
+		t.Errorf("expected member admitted, got %v", err)
+	}
+	if err := Admit(room, t.Name(), "owner", "ip", "", ""); err != nil {
+		t.Errorf("expected owner admitted, got %v", err)
+	}
+	if err := Admit(room, t.Name(), "guest", "ip", "wrong", ""); err != ErrAccessDenied {
+		t.Errorf("expected wrong passcode denied, got %v", err)
+	}
+	if err := Admit(room, t.Name(), "guest", "ip", "hunter22", ""); err != nil {
+		t.Errorf("expected passcode admitted, got %v", err)
+	}
+	if err := Admit(domain.Room{}, t.Name(), "guest", "ip", "", ""); err != nil {
+		t.Errorf("expected public room admitted, got %v", err)
+	}
+}
+
+func TestAdmit_RefusesBannedUsersEvenWithPasscode(t *testing.T) {
+	setAccessConfig(t)
+	now := time.Now()
+	room := privateRoom(t, "hunter22")
+	room.Ban("guest", "owner", now, now.Add(time.Hour))
+
+	if err := Admit(room, t.Name(), "guest", "ip", "hunter22", ""); err != ErrBanned {
+		t.Errorf("expected banned user refused, got %v", err)
+	}
+	public := domain.Room{}
+	public.Ban("guest", "owner", now, now.Add(time.Hour))
+	if err := Admit(public, t.Name(), "guest", "ip", "", ""); err != ErrBanned {
+		t.Errorf("expected banned user refused from a public room, got %v", err)
+	}
+}
+
+func TestAdmit_ThrottlesPerRoomAndAddress(t *testing.T) {
+	setAccessConfig(t)
+	room := privateRoom(t, "hunter22")
+
+	for i := 0; i < configs.Conf.JoinAttemptsMax; i++ {
+		if err := Admit(room, t.Name(), "guest", "1.2.3.4", "wrong", ""); err != ErrAccessDenied {
+			t.Fatalf("attempt %d: expected ErrAccessDenied, got %v", i, err)
+		}
+	}
+	if err := Admit(room, t.Name(), "guest", "1.2.3.4", "hunter22", ""); err != ErrTooManyAttempts {
+		t.Errorf("expected the right passcode refused once throttled, got %v", err)
+	}
+	if err := Admit(room, t.Name(), "guest", "5.6.7.8", "hunter22", ""); err != nil {
+		t.Errorf("expected another address unaffected, got %v", err)
+	}
+}
+
+func TestInvite_AdmitsUntilPasscodeChanges(t *testing.T) {
+	setAccessConfig(t)
+	room := privateRoom(t, "hunter22")
+
+	invite, err := CreateInvite(t.Name(), room.PasscodeHash, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Admit(room, t.Name(), "guest", "ip", "", invite.Token); err != nil {
+		t.Errorf("expected invite admitted, got %v", err)
+	}
+	if err := Admit(room, "other-room", "guest", "ip", "", invite.Token); err != ErrAccessDenied {
+		t.Errorf("expected invite for another room denied, got %v", err)
+	}
+
+	rotated := privateRoom(t, "hunter23")
+	if err := Admit(rotated, t.Name(), "guest", "ip", "", invite.Token); err != ErrAccessDenied {
+		t.Errorf("expected invite revoked by the new passcode, got %v", err)
+	}
+}
+
+func TestInvite_RejectsTamperedAndExpiredTokens(t *testing.T) {
+	setAccessConfig(t)
+
+	invite, err := CreateInvite("room", "", 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(invite.ExpiresAt) > configs.Conf.InviteTTL {
+		t.Errorf("expected ttl capped at INVITE_TTL, expires at %s", invite.ExpiresAt)
+	}
+	if err := verifyInvite(invite.Token+"x", "room", ""); err != errInvalidInvite {
+		t.Errorf("expected tampered token rejected, got %v", err)
+	}
+
+	claims, _ := json.Marshal(inviteClaims{RoomID: "room", ExpiresAt: time.Now().Add(-time.Second).Unix(), Passcode: passcodeFingerprint("")})
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	if err := verifyInvite(payload+"."+sign(configs.Conf.InviteSecret, payload), "room", ""); err != errExpiredInvite {
+		t.Errorf("expected expired token rejected, got %v", err)
+	}
+
+	configs.Conf.InviteSecret = ""
+	if _, err := CreateInvite("room", "", 0); err != ErrInvitesDisabled {
+		t.Errorf("expected ErrInvitesDisabled, got %v", err)
+	}
+}
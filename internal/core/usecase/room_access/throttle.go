@@ -0,0 +1,77 @@
+package roomaccess
+
+import (
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+)
+
+// maxTrackedAttempts bounds the throttle's memory; past it, expired entries
+// are swept before a new one is added.
+const maxTrackedAttempts = 10000
+
+type attemptKey struct {
+	roomId     string
+	remoteAddr string
+}
+
+type attemptWindow struct {
+	failures int
+	start    time.Time
+}
+
+// throttle counts failed join attempts per room and address in fixed
+// windows of JOIN_ATTEMPTS_WINDOW.
+type throttle struct {
+	mu      sync.Mutex
+	windows map[attemptKey]*attemptWindow
+}
+
+var attempts = &throttle{windows: make(map[attemptKey]*attemptWindow)}
+
+func (t *throttle) allowed(key attemptKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.current(key, time.Now())
+	return w == nil || w.failures < configs.Conf.JoinAttemptsMax
+}
+
+func (t *throttle) fail(key attemptKey) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.current(key, now)
+	if w == nil {
+		if len(t.windows) >= maxTrackedAttempts {
+			t.sweep(now)
+		}
+		w = &attemptWindow{start: now}
+		t.windows[key] = w
+	}
+	w.failures++
+}
+
+func (t *throttle) reset(key attemptKey) {
+	t.mu.Lock()
+	delete(t.windows, key)
+	t.mu.Unlock()
+}
+
+// current returns key's window if it has not expired yet.
+func (t *throttle) current(key attemptKey, now time.Time) *attemptWindow {
+	w := t.windows[key]
+	if w != nil && now.Sub(w.start) >= configs.Conf.JoinAttemptsWindow {
+		delete(t.windows, key)
+		return nil
+	}
+	return w
+}
+
+func (t *throttle) sweep(now time.Time) {
+	for key, w := range t.windows {
+		if now.Sub(w.start) >= configs.Conf.JoinAttemptsWindow {
+			delete(t.windows, key)
+		}
+	}
+}
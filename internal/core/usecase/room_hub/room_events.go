@@ -0,0 +1,356 @@
+package roomhub
+
+import (
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+)
+
+// Outbound actions. UPDATE_ROOM carries the full room and is only sent on
+// connect and in reply to SYNC; every other change is sent as a delta whose
+// version is the room version after the change. A client ignores deltas at
+// or below its own version and, on any version above its own plus one, sends
+// SYNC and waits for a snapshot.
+const (
+	ActionUpdateRoom    = "UPDATE_ROOM"
+	ActionNeedToJoin    = "NEED_TO_JOIN"
+	ActionMemberJoined  = "MEMBER_JOINED"
+	ActionMemberUpdated = "MEMBER_UPDATED"
+	ActionMemberLeft    = "MEMBER_LEFT"
+	ActionMemberKicked  = "MEMBER_KICKED"
+	ActionVoteCast      = "VOTE_CAST"
+	ActionCardsRevealed = "CARDS_REVEALED"
+	ActionRoundStarted  = "ROUND_STARTED"
+	ActionTicketChanged = "TICKET_CHANGED"
+	ActionQueueChanged  = "QUEUE_CHANGED"
+	ActionFinalScoreSet = "FINAL_SCORE_SET"
+	ActionEmojiThrown   = "EMOJI_THROWN"
+	ActionAsyncChanged  = "ASYNC_SESSION_CHANGED"
+	ActionNoteAdded     = "NOTE_ADDED"
+
+	ActionSettingsChanged = "ROOM_SETTINGS_CHANGED"
+	// ROOM_DELETED is the last frame a room sends before its connections are
+	// closed.
+	ActionRoomDeleted = "ROOM_DELETED"
+
+	// Presence is tracked in memory and carries no room version.
+	ActionPresenceChanged = "PRESENCE_CHANGED"
+	ActionPresenceState   = "PRESENCE_STATE"
+)
+
+// Message is the envelope of every server-to-client frame. Seq is set on
+// room events and snapshots; direct replies such as NEED_TO_JOIN carry none.
+type Message struct {
+	Action  string      `json:"action"`
+	Payload interface{} `json:"payload"`
+	Seq     int64       `json:"seq,omitempty"`
+
+	// payloadFor, when set, builds the payload each recipient gets. Payload
+	// is then the one for no user in particular.
+	payloadFor func(uid string) interface{}
+}
+
+// For returns the message as the user uid receives it. The hub sends every
+// room event through it.
+func (m Message) For(uid string) Message {
+	if m.payloadFor != nil {
+		m.Payload = m.payloadFor(uid)
+		m.payloadFor = nil
+	}
+	return m
+}
+
+// perRecipient builds a message whose payload depends on who receives it.
+func perRecipient(action string, payloadFor func(uid string) interface{}) Message {
+	return Message{Action: action, Payload: payloadFor(""), payloadFor: payloadFor}
+}
+
+// MemberJoinedPayload is shared by MEMBER_JOINED and MEMBER_UPDATED. Both
+// replace the member if the ID is already present.
+type MemberJoinedPayload struct {
+	Version int64         `json:"version"`
+	Member  domain.Member `json:"member"`
+}
+
+type MemberLeftPayload struct {
+	Version  int64          `json:"version"`
+	MemberID string         `json:"member_id"`
+	Result   map[string]int `json:"result"`
+}
+
+// MemberKickedPayload is MemberLeftPayload for a member someone else
+// removed. BannedUntil is set when they were banned too.
+type MemberKickedPayload struct {
+	Version     int64          `json:"version"`
+	MemberID    string         `json:"member_id"`
+	Result      map[string]int `json:"result"`
+	BannedUntil *time.Time     `json:"banned_until,omitempty"`
+}
+
+// VoteCastPayload carries EstimatedValue only to the voter, or to everyone
+// once the cards are revealed in a room that is not anonymous. Result is
+// empty until the reveal; HasVoted is always set.
+type VoteCastPayload struct {
+	Version        int64          `json:"version"`
+	MemberID       string         `json:"member_id"`
+	EstimatedValue string         `json:"estimated_value"`
+	HasVoted       bool           `json:"has_voted"`
+	Result         map[string]int `json:"result"`
+	// DimensionValues goes with EstimatedValue in a multi-dimensional room,
+	// and Confidence whenever the voter gave one.
+	DimensionValues map[string]string `json:"dimension_values,omitempty"`
+	Confidence      int               `json:"confidence,omitempty"`
+}
+
+// RoundStatePayload is shared by CARDS_REVEALED, ROUND_STARTED and
+// FINAL_SCORE_SET: each of them changes the round status together with the
+// active ticket and the scores stamped onto the queue.
+type RoundStatePayload struct {
+	Version          int64                     `json:"version"`
+	Status           string                    `json:"status"`
+	Result           map[string]int            `json:"result"`
+	FinalStoryPoint  string                    `json:"final_story_point"`
+	TicketEstimation *domain.TicketEstimation  `json:"ticket_estimation"`
+	TicketQueue      []domain.TicketEstimation `json:"ticket_queue"`
+	// Votes is set on CARDS_REVEALED only, as member ID to value, unless
+	// the room votes anonymously.
+	Votes map[string]string `json:"votes,omitempty"`
+	// Round is set on the CARDS_REVEALED that added it to the history.
+	Round *domain.Round `json:"round,omitempty"`
+	// DimensionStats is set from the reveal on in a multi-dimensional room,
+	// and DimensionVotes, member ID to dimension key to card, along with
+	// Votes.
+	DimensionStats map[string]domain.DimensionStats `json:"dimension_stats,omitempty"`
+	DimensionVotes map[string]map[string]string     `json:"dimension_votes,omitempty"`
+	// ConfidenceStats is set from the reveal on when any vote carried a
+	// confidence, and Confidences, member ID to confidence, along with Votes.
+	ConfidenceStats *domain.ConfidenceStats `json:"confidence_stats,omitempty"`
+	Confidences     map[string]int          `json:"confidences,omitempty"`
+	// DelphiRounds are the reveals of the active ticket so far, and
+	// Convergence how the last one moved; AwaitingFacilitator asks the owner
+	// to pick the final score.
+	DelphiRounds        []domain.DelphiRound `json:"delphi_rounds"`
+	Convergence         string               `json:"convergence"`
+	AwaitingFacilitator bool                 `json:"awaiting_facilitator"`
+}
+
+type TicketChangedPayload struct {
+	Version          int64                    `json:"version"`
+	TicketEstimation *domain.TicketEstimation `json:"ticket_estimation"`
+}
+
+type QueueChangedPayload struct {
+	Version          int64                     `json:"version"`
+	TicketEstimation *domain.TicketEstimation  `json:"ticket_estimation"`
+	TicketQueue      []domain.TicketEstimation `json:"ticket_queue"`
+}
+
+// AsyncChangedPayload is sent when an async session starts, takes a vote or
+// closes. Async is nil once the session is closed, and Rounds are the
+// reveals the change added to the history, their scores stamped onto
+// TicketQueue.
+type AsyncChangedPayload struct {
+	Version     int64                     `json:"version"`
+	Async       *domain.AsyncSession      `json:"async"`
+	TicketQueue []domain.TicketEstimation `json:"ticket_queue"`
+	Rounds      []domain.Round            `json:"rounds,omitempty"`
+}
+
+// NoteAddedPayload carries a note added to the active ticket. The latest
+// round of the ticket in the history takes it too.
+type NoteAddedPayload struct {
+	Version int64             `json:"version"`
+	Note    domain.TicketNote `json:"note"`
+}
+
+// NeedToJoinPayload tells a client that is not a member what joining takes.
+// A private room expects a passcode or invite with JOIN_ROOM.
+type NeedToJoinPayload struct {
+	Private bool `json:"private"`
+}
+
+// SettingsChangedPayload is sent when the owner renames the room, swaps its
+// deck, changes its passcode, voting mode, dimensions or Delphi round cap.
+type SettingsChangedPayload struct {
+	Version    int64  `json:"version"`
+	Name       string `json:"name"`
+	DeskConfig string `json:"desk_config"`
+	Private    bool   `json:"private"`
+	// AnonymousVoting and RecordVotes are the room's voting mode.
+	AnonymousVoting bool                 `json:"anonymous_voting"`
+	RecordVotes     bool                 `json:"record_votes"`
+	Dimensions      []domain.Dimension   `json:"dimensions"`
+	ScoreFormula    *domain.ScoreFormula `json:"score_formula"`
+	DelphiMaxRounds int                  `json:"delphi_max_rounds"`
+	Result          map[string]int       `json:"result"`
+	// WithdrawnVotes lists the members whose vote a deck change cleared.
+	WithdrawnVotes []string `json:"withdrawn_votes,omitempty"`
+}
+
+// Every event built from a room goes out as its View, or ViewFor each
+// recipient where it carries votes, so no frame shows a vote before the
+// reveal to anyone but its voter.
+
+func Snapshot(room domain.Room) Message {
+	return perRecipient(ActionUpdateRoom, func(uid string) interface{} {
+		return room.ViewFor(uid)
+	})
+}
+
+func NeedToJoin(room domain.Room) Message {
+	return Message{Action: ActionNeedToJoin, Payload: NeedToJoinPayload{Private: room.Private}}
+}
+
+func MemberJoined(room domain.Room, memberID string) Message {
+	return perRecipient(ActionMemberJoined, func(uid string) interface{} {
+		payload := MemberJoinedPayload{Version: room.Version}
+		if member, ok := findMember(room.ViewFor(uid), memberID); ok {
+			payload.Member = member
+		}
+		return payload
+	})
+}
+
+func MemberUpdated(room domain.Room, memberID string) Message {
+	message := MemberJoined(room, memberID)
+	message.Action = ActionMemberUpdated
+	return message
+}
+
+func MemberLeft(room domain.Room, memberID string) Message {
+	return Message{Action: ActionMemberLeft, Payload: MemberLeftPayload{
+		Version:  room.Version,
+		MemberID: memberID,
+		Result:   room.View().Result,
+	}}
+}
+
+func MemberKicked(room domain.Room, memberID string, now time.Time) Message {
+	payload := MemberKickedPayload{Version: room.Version, MemberID: memberID, Result: room.View().Result}
+	if until, banned := room.BannedUntil(memberID, now); banned {
+		payload.BannedUntil = &until
+	}
+	return Message{Action: ActionMemberKicked, Payload: payload}
+}
+
+func VoteCast(room domain.Room, memberID string) Message {
+	return perRecipient(ActionVoteCast, func(uid string) interface{} {
+		view := room.ViewFor(uid)
+		payload := VoteCastPayload{Version: room.Version, MemberID: memberID, Result: view.Result}
+		if member, ok := findMember(view, memberID); ok {
+			payload.EstimatedValue = member.EstimatedValue
+			payload.HasVoted = member.HasVoted
+			payload.DimensionValues = member.DimensionValues
+			payload.Confidence = member.Confidence
+		}
+		return payload
+	})
+}
+
+func CardsRevealed(room domain.Room) Message {
+	view := room.View()
+	payload := roundState(view)
+	if !room.AnonymousVoting {
+		payload.Votes = make(map[string]string, len(room.Members))
+		for _, m := range room.Members {
+			payload.Votes[m.ID] = m.EstimatedValue
+			if m.Confidence > 0 {
+				if payload.Confidences == nil {
+					payload.Confidences = map[string]int{}
+				}
+				payload.Confidences[m.ID] = m.Confidence
+			}
+		}
+		if room.MultiDimensional() {
+			payload.DimensionVotes = make(map[string]map[string]string, len(room.Members))
+			for _, m := range room.Members {
+				payload.DimensionVotes[m.ID] = m.DimensionValues
+			}
+		}
+	}
+	// Revealing again records no new round, and keeps the older UpdatedAt
+	// off the last one.
+	if n := len(view.Rounds); n > 0 && view.Rounds[n-1].RevealedAt.Equal(room.UpdatedAt) {
+		payload.Round = &view.Rounds[n-1]
+	}
+	return Message{Action: ActionCardsRevealed, Payload: payload}
+}
+
+func RoundStarted(room domain.Room) Message {
+	return Message{Action: ActionRoundStarted, Payload: roundState(room.View())}
+}
+
+func FinalScoreSet(room domain.Room) Message {
+	return Message{Action: ActionFinalScoreSet, Payload: roundState(room.View())}
+}
+
+func TicketChanged(room domain.Room) Message {
+	return Message{Action: ActionTicketChanged, Payload: TicketChangedPayload{
+		Version:          room.Version,
+		TicketEstimation: room.TicketEstimation,
+	}}
+}
+
+func NoteAdded(room domain.Room, note domain.TicketNote) Message {
+	return Message{Action: ActionNoteAdded, Payload: NoteAddedPayload{Version: room.Version, Note: note}}
+}
+
+func QueueChanged(room domain.Room) Message {
+	return Message{Action: ActionQueueChanged, Payload: QueueChangedPayload{
+		Version:          room.Version,
+		TicketEstimation: room.TicketEstimation,
+		TicketQueue:      room.TicketQueue,
+	}}
+}
+
+func SettingsChanged(room domain.Room, withdrawnVotes []string) Message {
+	return Message{Action: ActionSettingsChanged, Payload: SettingsChangedPayload{
+		Version:         room.Version,
+		Name:            room.Name,
+		DeskConfig:      room.DeskConfig,
+		Private:         room.Private,
+		AnonymousVoting: room.AnonymousVoting,
+		RecordVotes:     room.RecordVotes,
+		Dimensions:      room.Dimensions,
+		ScoreFormula:    room.ScoreFormula,
+		DelphiMaxRounds: room.DelphiMaxRounds,
+		Result:          room.View().Result,
+		WithdrawnVotes:  withdrawnVotes,
+	}}
+}
+
+func AsyncChanged(room domain.Room, rounds []domain.Round) Message {
+	return perRecipient(ActionAsyncChanged, func(uid string) interface{} {
+		view := room.ViewFor(uid)
+		payload := AsyncChangedPayload{Version: room.Version, Async: view.Async, TicketQueue: view.TicketQueue}
+		if n := len(rounds); n > 0 && n <= len(view.Rounds) {
+			payload.Rounds = view.Rounds[len(view.Rounds)-n:]
+		}
+		return payload
+	})
+}
+
+func roundState(room domain.Room) RoundStatePayload {
+	return RoundStatePayload{
+		Version:             room.Version,
+		Status:              room.Status,
+		Result:              room.Result,
+		FinalStoryPoint:     room.FinalStoryPoint,
+		TicketEstimation:    room.TicketEstimation,
+		TicketQueue:         room.TicketQueue,
+		DimensionStats:      room.DimensionStats,
+		ConfidenceStats:     room.ConfidenceStats,
+		DelphiRounds:        room.DelphiRounds,
+		Convergence:         room.Convergence,
+		AwaitingFacilitator: room.AwaitingFacilitator,
+	}
+}
+
+func findMember(room domain.Room, memberID string) (domain.Member, bool) {
+	for _, m := range room.Members {
+		if m.ID == memberID {
+			return m, true
+		}
+	}
+	return domain.Member{}, false
+}
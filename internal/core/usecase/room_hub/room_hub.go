@@ -0,0 +1,193 @@
+package roomhub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// Conn is the transport a subscriber is reached through. Implementations
+// must accept Close being called while another goroutine is writing.
+type Conn interface {
+	WriteJSON(v interface{}) error
+	Close(reason string) error
+}
+
+// Client is one live connection to a room. All writes go through Send so
+// that broadcasts and direct replies never interleave on the same socket.
+type Client struct {
+	RoomID      string
+	UID         string
+	RemoteAddr  string
+	ConnectedAt time.Time
+
+	conn    Conn
+	writeMu sync.Mutex
+	// lastActive is the time of the client's last action, guarded by presenceMu.
+	lastActive time.Time
+}
+
+// ClientInfo is the read-only view of a Client exposed to admin tooling.
+type ClientInfo struct {
+	UID         string    `json:"uid"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+var (
+	// clients holds every open connection, mapped to whether it receives
+	// its room's events. A socket to a private room it may not see is
+	// connected but not registered.
+	clients   = make(map[*Client]bool)
+	clientsMu sync.Mutex
+)
+
+func NewClient(conn Conn, roomId, uid, remoteAddr string) *Client {
+	now := time.Now()
+	return &Client{
+		RoomID:      roomId,
+		UID:         uid,
+		RemoteAddr:  remoteAddr,
+		ConnectedAt: now,
+		conn:        conn,
+		lastActive:  now,
+	}
+}
+
+func (c *Client) Send(message interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(message)
+}
+
+func (c *Client) Close(reason string) error {
+	return c.conn.Close(reason)
+}
+
+// Connect records c as open without subscribing it to its room's events,
+// so that closing the room or its user reaches it too.
+func Connect(c *Client) {
+	clientsMu.Lock()
+	if _, ok := clients[c]; !ok {
+		clients[c] = false
+	}
+	clientsMu.Unlock()
+}
+
+func Register(c *Client) {
+	clientsMu.Lock()
+	clients[c] = true
+	clientsMu.Unlock()
+}
+
+func Unregister(c *Client) {
+	clientsMu.Lock()
+	delete(clients, c)
+	clientsMu.Unlock()
+
+	RefreshPresence(c.RoomID, c.UID)
+	sweepStreams()
+}
+
+// Registered reports whether c receives its room's events.
+func Registered(c *Client) bool {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	return clients[c]
+}
+
+// roomClients snapshots the registered clients of a room so sends happen
+// without holding the registry lock.
+func roomClients(roomId string) []*Client {
+	return roomConnections(roomId, true)
+}
+
+// roomConnections snapshots the connections to a room, or only the
+// registered ones when registeredOnly is set.
+func roomConnections(roomId string, registeredOnly bool) []*Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	var result []*Client
+	for c, registered := range clients {
+		if c.RoomID == roomId && (registered || !registeredOnly) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Broadcast publishes message to every client of the room.
+func Broadcast(roomId string, message Message) {
+	streamFor(roomId).publish(roomId, message, nil)
+}
+
+// BroadcastToOthers publishes message to every client of the room except
+// sender. Other connections of the same user still receive it live, but
+// replay skips the sender's user entirely.
+func BroadcastToOthers(sender *Client, roomId string, message Message) {
+	streamFor(roomId).publish(roomId, message, sender)
+}
+
+func Clients(roomId string) []ClientInfo {
+	roomClientList := roomConnections(roomId, false)
+	infos := make([]ClientInfo, 0, len(roomClientList))
+	for _, c := range roomClientList {
+		infos = append(infos, ClientInfo{
+			UID:         c.UID,
+			RemoteAddr:  c.RemoteAddr,
+			ConnectedAt: c.ConnectedAt,
+		})
+	}
+	return infos
+}
+
+// ConnectionCounts returns the number of live connections per room on this
+// instance.
+func ConnectionCounts() map[string]int {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	counts := map[string]int{}
+	for c := range clients {
+		counts[c.RoomID]++
+	}
+	return counts
+}
+
+// CloseRoom closes every connection to the room, registered or not, and
+// returns how many were closed. The read loops notice the closed sockets and
+// unregister themselves.
+func CloseRoom(roomId, reason string) int {
+	roomClientList := roomConnections(roomId, false)
+	for _, c := range roomClientList {
+		if err := c.Close(reason); err != nil {
+			logger.Warn("error closing client connection", "roomId", roomId, "uid", c.UID, "error", err)
+		}
+	}
+	return len(roomClientList)
+}
+
+// CloseUser closes every connection uid holds to the room and returns how
+// many were closed.
+func CloseUser(roomId, uid, reason string) int {
+	closed := 0
+	for _, c := range roomConnections(roomId, false) {
+		if c.UID != uid {
+			continue
+		}
+		if err := c.Close(reason); err != nil {
+			logger.Warn("error closing client connection", "roomId", roomId, "uid", uid, "error", err)
+		}
+		closed++
+	}
+	return closed
+}
+
+// CloseDeletedRoom tells everyone in the room that it was deleted, then
+// closes their connections and returns how many were closed.
+func CloseDeletedRoom(roomId, reason string) int {
+	Broadcast(roomId, Message{Action: ActionRoomDeleted})
+	return CloseRoom(roomId, reason)
+}
@@ -0,0 +1,153 @@
+package roomhub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+)
+
+// Presence statuses. A member is online while any of their connections has
+// seen an action within PRESENCE_AWAY_AFTER, away while connected but idle,
+// and offline once their last connection is gone.
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+type presenceKey struct {
+	roomId string
+	uid    string
+}
+
+type memberPresence struct {
+	status        string
+	lastActive    time.Time
+	lastPersisted time.Time
+}
+
+// presence holds the last status broadcast for every member with a live
+// connection. presenceMu also guards Client.lastActive.
+var (
+	presence   = make(map[presenceKey]*memberPresence)
+	presenceMu sync.Mutex
+)
+
+type PresenceChangedPayload struct {
+	MemberID     string    `json:"member_id"`
+	Status       string    `json:"status"`
+	LastActiveAt time.Time `json:"last_active_at"`
+}
+
+type PresenceStatePayload struct {
+	Members []PresenceChangedPayload `json:"members"`
+}
+
+// MarkActive records an action from client. It reports whether the member's
+// last_active_at is due to be persisted, which happens at most once per
+// PRESENCE_PERSIST_INTERVAL.
+func MarkActive(client *Client) bool {
+	now := time.Now()
+	presenceMu.Lock()
+	client.lastActive = now
+	persist := persistDueLocked(presenceKey{client.RoomID, client.UID}, now)
+	presenceMu.Unlock()
+
+	RefreshPresence(client.RoomID, client.UID)
+	return persist
+}
+
+// MarkUserActive records an action a member took without a connection, such
+// as a REST call, against every connection they have to the room.
+func MarkUserActive(roomId, uid string) bool {
+	now := time.Now()
+	presenceMu.Lock()
+	for _, c := range roomClients(roomId) {
+		if c.UID == uid {
+			c.lastActive = now
+		}
+	}
+	persist := persistDueLocked(presenceKey{roomId, uid}, now)
+	presenceMu.Unlock()
+
+	RefreshPresence(roomId, uid)
+	return persist
+}
+
+func persistDueLocked(key presenceKey, now time.Time) bool {
+	p := presence[key]
+	if p == nil || now.Sub(p.lastPersisted) < configs.Conf.PresencePersistInterval {
+		return false
+	}
+	p.lastPersisted = now
+	return true
+}
+
+// RefreshPresence recomputes a member's status from their connections and
+// broadcasts PRESENCE_CHANGED when it differs from the last one sent.
+func RefreshPresence(roomId, uid string) {
+	// Anonymous subscribers, such as read-only event streams, have no presence.
+	if uid == "" {
+		return
+	}
+	key := presenceKey{roomId, uid}
+	now := time.Now()
+
+	presenceMu.Lock()
+	status, lastActive := PresenceOffline, time.Time{}
+	for _, c := range roomClients(roomId) {
+		if c.UID != uid {
+			continue
+		}
+		if c.lastActive.After(lastActive) {
+			lastActive = c.lastActive
+		}
+		status = PresenceAway
+	}
+	if status == PresenceAway && now.Sub(lastActive) < configs.Conf.PresenceAwayAfter {
+		status = PresenceOnline
+	}
+
+	p, known := presence[key]
+	if !known {
+		if status == PresenceOffline {
+			presenceMu.Unlock()
+			return
+		}
+		// A fresh connection is not proof the stored timestamp is stale, so
+		// the first persist waits a full interval like any other.
+		p = &memberPresence{lastPersisted: now}
+		presence[key] = p
+	}
+	if lastActive.After(p.lastActive) {
+		p.lastActive = lastActive
+	}
+	changed := p.status != status
+	p.status = status
+	payload := PresenceChangedPayload{MemberID: uid, Status: status, LastActiveAt: p.lastActive}
+	if status == PresenceOffline {
+		delete(presence, key)
+	}
+	presenceMu.Unlock()
+
+	if changed {
+		Broadcast(roomId, Message{Action: ActionPresenceChanged, Payload: payload})
+	}
+}
+
+// PresenceState returns the status of every connected member of the room,
+// sent to a client on connect and with every snapshot it asks for.
+func PresenceState(roomId string) Message {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	members := []PresenceChangedPayload{}
+	for key, p := range presence {
+		if key.roomId != roomId {
+			continue
+		}
+		members = append(members, PresenceChangedPayload{MemberID: key.uid, Status: p.status, LastActiveAt: p.lastActive})
+	}
+	return Message{Action: ActionPresenceState, Payload: PresenceStatePayload{Members: members}}
+}
@@ -0,0 +1,76 @@
+package roomhub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+)
+
+func presenceEvents(conn *recordingConn, uid string) []PresenceChangedPayload {
+	var events []PresenceChangedPayload
+	for _, m := range conn.sent {
+		if m.Action != ActionPresenceChanged {
+			continue
+		}
+		if p := m.Payload.(PresenceChangedPayload); p.MemberID == uid {
+			events = append(events, p)
+		}
+	}
+	return events
+}
+
+func setPresenceConfig(t *testing.T, awayAfter, persistInterval time.Duration) {
+	t.Helper()
+	prev := configs.Conf
+	configs.Conf.PresenceAwayAfter = awayAfter
+	configs.Conf.PresencePersistInterval = persistInterval
+	t.Cleanup(func() { configs.Conf = prev })
+}
+
+func TestPresence_MultipleTabs(t *testing.T) {
+	setPresenceConfig(t, time.Minute, time.Hour)
+	roomId := t.Name()
+
+	_, watcher, _ := attach(t, roomId, "bob", 0)
+	tab1, _, _ := attach(t, roomId, "alice", 0)
+	tab2, _, _ := attach(t, roomId, "alice", 0)
+
+	Unregister(tab1)
+	Unregister(tab2)
+
+	events := presenceEvents(watcher, "alice")
+	if len(events) != 2 {
+		t.Fatalf("expected alice online then offline, got %+v", events)
+	}
+	if events[0].Status != PresenceOnline || events[1].Status != PresenceOffline {
+		t.Errorf("unexpected presence events: %+v", events)
+	}
+}
+
+func TestPresence_AwayAfterIdle(t *testing.T) {
+	setPresenceConfig(t, time.Minute, time.Hour)
+	roomId := t.Name()
+
+	client, conn, _ := attach(t, roomId, "alice", 0)
+
+	presenceMu.Lock()
+	client.lastActive = time.Now().Add(-2 * time.Minute)
+	presenceMu.Unlock()
+	RefreshPresence(roomId, "alice")
+
+	if persist := MarkActive(client); persist {
+		t.Error("expected no persist within the interval")
+	}
+
+	events := presenceEvents(conn, "alice")
+	want := []string{PresenceOnline, PresenceAway, PresenceOnline}
+	if len(events) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, events)
+	}
+	for i, status := range want {
+		if events[i].Status != status {
+			t.Errorf("event %d: expected %s, got %s", i, status, events[i].Status)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package roomhub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+// roomStream numbers the events of one room and keeps the most recent ones
+// so a client that drops off briefly can resume without a snapshot.
+//
+// Sequence numbers start at the stream's creation time in microseconds
+// rather than at zero, so a stream recreated after eviction or a restart
+// always numbers above anything a client may still hold from the old one,
+// and such a client falls back to a snapshot instead of a wrong replay.
+type roomStream struct {
+	mu sync.Mutex
+	// changeMu is held by Serialize, for as long as a change to the room is
+	// stored and published.
+	changeMu sync.Mutex
+	seq      int64
+	buffer   []bufferedMessage
+	lastUsed time.Time
+}
+
+type bufferedMessage struct {
+	message Message
+	// senderUID is excluded from replay, matching BroadcastToOthers.
+	senderUID string
+}
+
+var (
+	streams   = make(map[string]*roomStream)
+	streamsMu sync.Mutex
+)
+
+func streamFor(roomId string) *roomStream {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	s, ok := streams[roomId]
+	if !ok {
+		now := time.Now()
+		s = &roomStream{seq: now.UnixMicro(), lastUsed: now}
+		streams[roomId] = s
+	}
+	return s
+}
+
+// publish stamps the next sequence number on message, buffers it and sends
+// it to every client of the room except sender. Holding the stream lock while
+// sending keeps every client's view in sequence order.
+func (s *roomStream) publish(roomId string, message Message, sender *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	message.Seq = s.seq
+	s.lastUsed = time.Now()
+
+	entry := bufferedMessage{message: message}
+	if sender != nil {
+		entry.senderUID = sender.UID
+	}
+	s.buffer = append(s.buffer, entry)
+	if over := len(s.buffer) - configs.Conf.WSReplayBuffer; over > 0 {
+		s.buffer = append(s.buffer[:0:0], s.buffer[over:]...)
+	}
+
+	for _, c := range roomClients(roomId) {
+		if c == sender {
+			continue
+		}
+		if err := c.Send(message.For(c.UID)); err != nil {
+			logger.Error("error sending message to client", "error", err)
+		}
+	}
+}
+
+// missedSince returns the buffered events after lastSeq that uid should see,
+// or false when the buffer no longer covers the gap.
+func (s *roomStream) missedSince(lastSeq int64, uid string) ([]Message, bool) {
+	if lastSeq > s.seq {
+		return nil, false
+	}
+	if lastSeq < s.seq && (len(s.buffer) == 0 || s.buffer[0].message.Seq > lastSeq+1) {
+		return nil, false
+	}
+
+	var missed []Message
+	for _, entry := range s.buffer {
+		if entry.message.Seq <= lastSeq || entry.senderUID == uid {
+			continue
+		}
+		missed = append(missed, entry.message.For(uid))
+	}
+	return missed, true
+}
+
+// sendSnapshotLocked sends the room as UPDATE_ROOM stamped with the current
+// sequence number. The caller holds s.mu.
+func (s *roomStream) sendSnapshotLocked(client *Client, load func() (domain.Room, error)) error {
+	room, err := load()
+	if err != nil {
+		return err
+	}
+	message := Snapshot(room).For(client.UID)
+	message.Seq = s.seq
+	return client.Send(message)
+}
+
+// Attach registers client with its room and brings it up to date. When
+// lastSeq is positive and every event after it is still buffered, those
+// events are replayed and resumed is true; otherwise a snapshot from load is
+// sent. No live event can overtake the replay or the snapshot.
+func Attach(client *Client, lastSeq int64, load func() (domain.Room, error)) (resumed bool, err error) {
+	resumed, err = attachLocked(client, lastSeq, load)
+	// Broadcasting takes the stream lock, so presence waits until it is free.
+	RefreshPresence(client.RoomID, client.UID)
+	return resumed, err
+}
+
+func attachLocked(client *Client, lastSeq int64, load func() (domain.Room, error)) (bool, error) {
+	s := streamFor(client.RoomID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastUsed = time.Now()
+	Register(client)
+
+	if lastSeq > 0 {
+		if missed, ok := s.missedSince(lastSeq, client.UID); ok {
+			for _, message := range missed {
+				if err := client.Send(message); err != nil {
+					return true, err
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, s.sendSnapshotLocked(client, load)
+}
+
+// DetachUser stops every connection uid holds to the room from receiving its
+// events, without closing them, and sends each of them message, after which
+// no event reaches them. It is used when a user can no longer see a private
+// room. Returns how many connections were detached.
+func DetachUser(roomId, uid string, message Message) int {
+	return DetachWhere(roomId, func(u string) bool { return u == uid }, message)
+}
+
+// DetachWhere is DetachUser for every user detach reports true for, anonymous
+// connections included under "". It is used when a room turns private.
+func DetachWhere(roomId string, detach func(uid string) bool, message Message) int {
+	uids := detachLocked(roomId, detach, message)
+	refreshed := map[string]bool{}
+	for _, uid := range uids {
+		if !refreshed[uid] {
+			refreshed[uid] = true
+			RefreshPresence(roomId, uid)
+		}
+	}
+	return len(uids)
+}
+
+// detachLocked returns the user of each connection it detached.
+func detachLocked(roomId string, detach func(uid string) bool, message Message) []string {
+	s := streamFor(roomId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var detached []string
+	for _, c := range roomClients(roomId) {
+		if !detach(c.UID) {
+			continue
+		}
+		clientsMu.Lock()
+		clients[c] = false
+		clientsMu.Unlock()
+		_ = c.Send(message.For(c.UID))
+		detached = append(detached, c.UID)
+	}
+	return detached
+}
+
+// SendSnapshot sends the room to one client, numbered consistently with
+// the live stream.
+func SendSnapshot(client *Client, load func() (domain.Room, error)) error {
+	s := streamFor(client.RoomID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendSnapshotLocked(client, load)
+}
+
+// Serialize runs fn while no other Serialize call for the room on this
+// instance does. Changes to a room run under it, so they are stored and then
+// published one at a time, and clients see them in the order of their
+// versions. fn must not call Serialize itself.
+func Serialize(roomId string, fn func()) {
+	for {
+		s := streamFor(roomId)
+		s.changeMu.Lock()
+		// The stream may have been swept while we waited for it; a change
+		// under a stale stream would not exclude one under its replacement.
+		streamsMu.Lock()
+		current := streams[roomId] == s
+		streamsMu.Unlock()
+		if current {
+			defer s.changeMu.Unlock()
+			fn()
+			return
+		}
+		s.changeMu.Unlock()
+	}
+}
+
+// sweepStreams drops the streams of rooms that have had no clients and no
+// events for longer than the replay retention.
+func sweepStreams() {
+	cutoff := time.Now().Add(-configs.Conf.WSReplayRetention)
+	counts := ConnectionCounts()
+
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	for roomId, s := range streams {
+		if counts[roomId] > 0 {
+			continue
+		}
+		// A stream that is busy attaching, publishing or serializing a change
+		// is not idle.
+		if !s.changeMu.TryLock() {
+			continue
+		}
+		if !s.mu.TryLock() {
+			s.changeMu.Unlock()
+			continue
+		}
+		idle := s.lastUsed.Before(cutoff)
+		s.mu.Unlock()
+		s.changeMu.Unlock()
+		if idle {
+			delete(streams, roomId)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package roomhub
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init("test")
+	os.Exit(m.Run())
+}
+
+type recordingConn struct {
+	sent   []Message
+	closed bool
+}
+
+func (c *recordingConn) WriteJSON(v interface{}) error {
+	c.sent = append(c.sent, v.(Message))
+	return nil
+}
+
+func (c *recordingConn) Close(string) error {
+	c.closed = true
+	return nil
+}
+
+func loadEmptyRoom() (domain.Room, error) { return domain.Room{Name: "snapshot"}, nil }
+
+func setReplayBuffer(t *testing.T, size int) {
+	t.Helper()
+	prev := configs.Conf.WSReplayBuffer
+	configs.Conf.WSReplayBuffer = size
+	t.Cleanup(func() { configs.Conf.WSReplayBuffer = prev })
+}
+
+func attach(t *testing.T, roomId, uid string, lastSeq int64) (*Client, *recordingConn, bool) {
+	t.Helper()
+	conn := &recordingConn{}
+	client := NewClient(conn, roomId, uid, "")
+	resumed, err := Attach(client, lastSeq, loadEmptyRoom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Unregister(client) })
+	return client, conn, resumed
+}
+
+func TestAttach_ReplaysMissedEvents(t *testing.T) {
+	setReplayBuffer(t, 10)
+	roomId := t.Name()
+
+	_, first, _ := attach(t, roomId, "alice", 0)
+	lastSeq := first.sent[len(first.sent)-1].Seq
+
+	Broadcast(roomId, Message{Action: ActionVoteCast})
+	Broadcast(roomId, Message{Action: ActionCardsRevealed})
+
+	_, conn, resumed := attach(t, roomId, "alice", lastSeq)
+	if !resumed {
+		t.Fatal("expected resume from buffered events")
+	}
+	if len(conn.sent) != 2 || conn.sent[0].Action != ActionVoteCast || conn.sent[1].Seq != lastSeq+2 {
+		t.Errorf("unexpected replay: %+v", conn.sent)
+	}
+}
+
+func TestAttach_SnapshotWhenGapTooBig(t *testing.T) {
+	setReplayBuffer(t, 1)
+	roomId := t.Name()
+
+	_, first, _ := attach(t, roomId, "alice", 0)
+	lastSeq := first.sent[len(first.sent)-1].Seq
+
+	Broadcast(roomId, Message{Action: ActionVoteCast})
+	Broadcast(roomId, Message{Action: ActionCardsRevealed})
+
+	_, conn, resumed := attach(t, roomId, "alice", lastSeq)
+	if resumed {
+		t.Fatal("expected snapshot, buffer no longer covers the gap")
+	}
+	if len(conn.sent) != 1 || conn.sent[0].Action != ActionUpdateRoom || conn.sent[0].Seq != lastSeq+2 {
+		t.Errorf("unexpected snapshot: %+v", conn.sent)
+	}
+
+	if _, conn, resumed = attach(t, roomId, "alice", lastSeq+100); resumed || conn.sent[0].Action != ActionUpdateRoom {
+		t.Error("expected snapshot for a seq from the future")
+	}
+}
+
+func TestAttach_ReplaySkipsOwnEmoji(t *testing.T) {
+	setReplayBuffer(t, 10)
+	roomId := t.Name()
+
+	sender, first, _ := attach(t, roomId, "alice", 0)
+	lastSeq := first.sent[len(first.sent)-1].Seq
+
+	BroadcastToOthers(sender, roomId, Message{Action: ActionEmojiThrown})
+	Broadcast(roomId, Message{Action: ActionTicketChanged})
+
+	_, conn, _ := attach(t, roomId, "alice", lastSeq)
+	if len(conn.sent) != 1 || conn.sent[0].Action != ActionTicketChanged {
+		t.Errorf("expected only the ticket event, got %+v", conn.sent)
+	}
+}
+
+func TestDetachUser_StopsEventsToEveryConnectionOfTheUser(t *testing.T) {
+	setReplayBuffer(t, 10)
+	roomId := t.Name()
+
+	tab1, conn1, _ := attach(t, roomId, "alice", 0)
+	tab2, conn2, _ := attach(t, roomId, "alice", 0)
+	_, other, _ := attach(t, roomId, "bob", 0)
+
+	if n := DetachUser(roomId, "alice", Message{Action: ActionNeedToJoin}); n != 2 {
+		t.Fatalf("detached %d connections, want 2", n)
+	}
+	if Registered(tab1) || Registered(tab2) {
+		t.Fatal("detached clients are still registered")
+	}
+
+	Broadcast(roomId, Message{Action: ActionVoteCast})
+	for _, conn := range []*recordingConn{conn1, conn2} {
+		if last := conn.sent[len(conn.sent)-1]; last.Action != ActionNeedToJoin {
+			t.Errorf("last frame to detached client = %s, want %s", last.Action, ActionNeedToJoin)
+		}
+	}
+	if last := other.sent[len(other.sent)-1]; last.Action != ActionVoteCast {
+		t.Errorf("last frame to bob = %s, want %s", last.Action, ActionVoteCast)
+	}
+}
+
+func TestDetachWhere_TurningPrivateDetachesOutsiders(t *testing.T) {
+	setReplayBuffer(t, 10)
+	roomId := t.Name()
+	room := domain.Room{Members: []domain.Member{{ID: "alice"}}, OwnerID: "alice"}
+
+	_, member, _ := attach(t, roomId, "alice", 0)
+	outsider, outsiderConn, _ := attach(t, roomId, "bob", 0)
+	anonymous, anonymousConn, _ := attach(t, roomId, "", 0)
+
+	room.SetPasscode("hash", time.Now())
+	if n := DetachWhere(roomId, func(uid string) bool { return !room.CanView(uid) }, NeedToJoin(room)); n != 2 {
+		t.Fatalf("detached %d connections, want 2", n)
+	}
+	if Registered(outsider) || Registered(anonymous) {
+		t.Fatal("outsiders are still registered")
+	}
+
+	Broadcast(roomId, Message{Action: ActionVoteCast})
+	for _, conn := range []*recordingConn{outsiderConn, anonymousConn} {
+		if last := conn.sent[len(conn.sent)-1]; last.Action != ActionNeedToJoin {
+			t.Errorf("last frame to outsider = %s, want %s", last.Action, ActionNeedToJoin)
+		}
+	}
+	if last := member.sent[len(member.sent)-1]; last.Action != ActionVoteCast {
+		t.Errorf("last frame to alice = %s, want %s", last.Action, ActionVoteCast)
+	}
+}
+
+func TestBroadcast_ShowsEachVoteOnlyToItsVoterUntilReveal(t *testing.T) {
+	setReplayBuffer(t, 10)
+	roomId := t.Name()
+	room := domain.Room{
+		Status:  "VOTING",
+		Members: []domain.Member{{ID: "alice", EstimatedValue: "5"}, {ID: "bob"}},
+		Result:  map[string]int{"5": 1},
+	}
+
+	_, voter, _ := attach(t, roomId, "alice", 0)
+	_, other, _ := attach(t, roomId, "bob", 0)
+	lastSeq := other.sent[len(other.sent)-1].Seq
+	Broadcast(roomId, VoteCast(room, "alice"))
+	_, replayed, _ := attach(t, roomId, "bob", lastSeq)
+
+	for _, tc := range []struct {
+		name string
+		conn *recordingConn
+		want string
+	}{
+		{"voter", voter, "5"},
+		{"other member", other, ""},
+		{"replay to other member", replayed, ""},
+	} {
+		var payload VoteCastPayload
+		for _, m := range tc.conn.sent {
+			if m.Action == ActionVoteCast {
+				payload = m.Payload.(VoteCastPayload)
+			}
+		}
+		if payload.EstimatedValue != tc.want || !payload.HasVoted || len(payload.Result) != 0 {
+			t.Errorf("%s got %+v, want estimated_value %q, has_voted and no result", tc.name, payload, tc.want)
+		}
+	}
+}
+
+func TestCloseDeletedRoom_ClosesUnregisteredConnections(t *testing.T) {
+	roomId := t.Name()
+	_, memberConn, _ := attach(t, roomId, "alice", 0)
+	waitingConn := &recordingConn{}
+	waiting := NewClient(waitingConn, roomId, "bob", "")
+	Connect(waiting)
+	t.Cleanup(func() { Unregister(waiting) })
+
+	if n := CloseDeletedRoom(roomId, "deleted"); n != 2 {
+		t.Fatalf("closed %d connections, want 2", n)
+	}
+	if !memberConn.closed || !waitingConn.closed {
+		t.Error("expected every connection closed")
+	}
+	if len(waitingConn.sent) != 0 {
+		t.Error("expected no event sent to the unregistered connection")
+	}
+}
+
+func TestSerialize_RunsChangesToARoomOneAtATime(t *testing.T) {
+	roomId := t.Name()
+	prev := configs.Conf.WSReplayRetention
+	configs.Conf.WSReplayRetention = 0
+	t.Cleanup(func() { configs.Conf.WSReplayRetention = prev })
+	var running, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Serialize(roomId, func() {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+		}()
+		// Sweeping idle streams must not let a change slip past one that
+		// holds a stream just dropped.
+		sweepStreams()
+	}
+	wg.Wait()
+	if overlaps != 0 {
+		t.Errorf("%d changes overlapped", overlaps)
+	}
+}
@@ -1,13 +1,23 @@
 package roomsocket
 
 import (
+	"errors"
+	"time"
+
+	"github.com/raksitnongbua/planning-poker-service/configs"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	idgenerator "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/id_generator"
 	roomService "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room"
+	roomaccess "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/room_access"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/timer"
 
 	repo "github.com/raksitnongbua/planning-poker-service/internal/repository/room"
 )
 
+// ErrMemberNotFound is returned for a uid that is not a member of the room
+// as it is loaded for the change.
+var ErrMemberNotFound = errors.New("member not found")
+
 func FindMemberIndex(members []domain.Member, targetId string) int {
 	for i, user := range members {
 		if user.ID == targetId {
@@ -19,129 +29,311 @@ func FindMemberIndex(members []domain.Member, targetId string) int {
 }
 
 func JoinRoom(id, name, picture, roomId string) (domain.Room, error) {
-	roomInfo := roomService.GetRoomInfo(roomId)
+	return repo.UpdateRoom(roomId, repo.NewJoinerFields, func(roomInfo *domain.Room) error {
+		now := timer.GetTimeNow()
+		roomInfo.JoinRoom(domain.NewMember(id, name, picture, now), now)
+		return nil
+	})
+}
 
-	now := timer.GetTimeNow()
-	newMember := domain.NewMember(id, name, picture, now)
+// LeaveRoom takes the member out of the room. Joining again later puts them
+// back without a vote.
+func LeaveRoom(id, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.LeaveRoomFields, func(roomInfo *domain.Room) error {
+		if !roomInfo.Leave(id, timer.GetTimeNow()) {
+			return ErrMemberNotFound
+		}
+		return nil
+	})
+}
 
-	roomInfo.JoinRoom(newMember, now)
+func UpdateProfile(uid, name, picture, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.MemberProfileFields, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		roomInfo.UpdateProfile(index, name, picture, timer.GetTimeNow())
+		return nil
+	})
+}
 
-	err := repo.UpdateNewJoiner(roomId, roomInfo)
+// UpdateEstimatedValue casts or withdraws the member's vote. A nil
+// confidence keeps the one they gave before.
+func UpdateEstimatedValue(uid, value string, confidence *int, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.EstimatedValueFields, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		if roomInfo.MultiDimensional() && value != "" {
+			return domain.ErrDimensionsRequired
+		}
+		roomInfo.UpdateEstimatedValue(index, value, timer.GetTimeNow())
+		if confidence != nil {
+			roomInfo.SetConfidence(index, *confidence)
+		}
 
-	if err != nil {
-		return domain.Room{}, err
-	}
-	return roomInfo, nil
+		// After update estimated value we need to recalculate result and update it.
+		roomInfo.UpdateResult()
+		return nil
+	})
+}
+
+// VoteDimensions records the member's cards on some of the room's
+// dimensions. A nil confidence keeps the one they gave before.
+func VoteDimensions(uid string, values map[string]string, confidence *int, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.EstimatedValueFields, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		if err := roomInfo.VoteDimensions(index, values, timer.GetTimeNow()); err != nil {
+			return err
+		}
+		if confidence != nil {
+			roomInfo.SetConfidence(index, *confidence)
+		}
+		roomInfo.UpdateResult()
+		return nil
+	})
 }
 
-func UpdateEstimatedValue(index int, value, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.UpdateEstimatedValue(index, value, now)
+func RevealCards(uid, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.RevealCardsFields, func(roomInfo *domain.Room) error {
+		now := timer.GetTimeNow()
+		actorIndex := FindMemberIndex(roomInfo.Members, uid)
+		if actorIndex == -1 {
+			return ErrMemberNotFound
+		}
+		firstReveal := roomInfo.Status != "REVEALED_CARDS"
+		roomInfo.RevealCards(actorIndex, now)
+		if firstReveal {
+			roomInfo.RecordDelphiRound(now)
+			roomInfo.RecordRound(now, configs.Conf.RoundHistoryLimit)
+		}
+		return nil
+	})
+}
+
+// TouchMember records that the member was just active. It does nothing for
+// a uid that is not a member.
+func TouchMember(uid, roomId string) error {
+	// Presence is broadcast from memory, so this write does not advance the
+	// version clients track. The members are read again in the transaction,
+	// so only this member's activity changes.
+	err := repo.UpdateLastActive(roomId, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		roomInfo.TouchMember(index, timer.GetTimeNow())
+		return nil
+	})
+	if errors.Is(err, ErrMemberNotFound) {
+		return nil
+	}
+	return err
+}
 
-	// After update estimated value we need to recalculate result and update it.
-	roomInfo.UpdateResult()
+func SetTicketEstimation(est *domain.TicketEstimation, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.TicketEstimationFields, func(roomInfo *domain.Room) error {
+		roomInfo.SetTicketEstimation(est, timer.GetTimeNow())
+		return nil
+	})
+}
 
-	err := repo.UpdateEstimatedValue(roomId, roomInfo)
+// AddNote adds a note by the member on the active ticket.
+func AddNote(uid, text, roomId string) (domain.Room, domain.TicketNote, error) {
+	var note domain.TicketNote
+	roomInfo, err := repo.UpdateRoom(roomId, repo.NoteFields, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		var err error
+		note, err = roomInfo.AddNote(idgenerator.GenerateUUID(), index, text, timer.GetTimeNow())
+		return err
+	})
 	if err != nil {
-		return domain.Room{}, err
+		return domain.Room{}, domain.TicketNote{}, err
 	}
+	return roomInfo, note, nil
+}
 
-	return roomInfo, nil
+// SetTicketDetails replaces the details of the ticket with the given key,
+// or of the active ticket when key is empty.
+func SetTicketDetails(roomId, key string, details domain.TicketDetails) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.TicketQueueFields, func(roomInfo *domain.Room) error {
+		return roomInfo.SetTicketDetails(key, details, timer.GetTimeNow())
+	})
 }
 
-func RevealCards(actorIndex int, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
+func SetTicketQueue(queue []domain.TicketEstimation, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.TicketQueueFields, func(roomInfo *domain.Room) error {
+		roomInfo.SetTicketQueue(queue, timer.GetTimeNow())
+		return nil
+	})
+}
 
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.RevealCards(actorIndex, now)
+func SetTicketQueueWithEstimation(queue []domain.TicketEstimation, est *domain.TicketEstimation, roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.TicketQueueFields, func(roomInfo *domain.Room) error {
+		now := timer.GetTimeNow()
+		roomInfo.SetTicketQueue(queue, now)
+		roomInfo.SetTicketEstimation(est, now)
+		return nil
+	})
+}
 
-	err := repo.SetRevealCards(roomId, roomInfo)
-	if err != nil {
-		return domain.Room{}, err
-	}
+func SetFinalStoryPoint(roomId string, value string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.FinalStoryPointFields, func(roomInfo *domain.Room) error {
+		roomInfo.ConfirmFinalStoryPoint(value, timer.GetTimeNow())
+		return nil
+	})
+}
 
-	return roomInfo, nil
+func ResetRoom(roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.ResetRoomFields, func(roomInfo *domain.Room) error {
+		roomInfo.Restart(timer.GetTimeNow())
+		return nil
+	})
 }
 
-func TouchMember(uid, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	index := FindMemberIndex(roomInfo.Members, uid)
-	if index == -1 {
-		return roomInfo, nil
-	}
-	roomInfo.TouchMember(index, now)
-	return roomInfo, repo.UpdateLastActive(roomId, roomInfo.Members, roomInfo.UpdatedAt)
+func ResetRoomWithTicket(roomId string, ticket domain.TicketEstimation, queue []domain.TicketEstimation) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.ResetRoomFields, func(roomInfo *domain.Room) error {
+		roomInfo.RestartWithTicket(ticket, queue, timer.GetTimeNow())
+		return nil
+	})
 }
 
-func SetTicketEstimation(est *domain.TicketEstimation, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.SetTicketEstimation(est, now)
-	err := repo.SetTicketEstimation(roomId, roomInfo)
-	if err != nil {
-		return domain.Room{}, err
-	}
-	return roomInfo, nil
+// Revote starts another round on the revealed ticket.
+func Revote(roomId string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.ResetRoomFields, func(roomInfo *domain.Room) error {
+		return roomInfo.Revote(timer.GetTimeNow())
+	})
 }
 
-func SetTicketQueue(queue []domain.TicketEstimation, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.SetTicketQueue(queue, now)
-	err := repo.SetTicketQueue(roomId, roomInfo)
+func RenameRoom(roomId, name string) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		roomInfo.Rename(name, timer.GetTimeNow())
+		return nil
+	})
+}
+
+// ChangeDeck swaps the room's deck and returns the members whose vote was
+// withdrawn by policy.
+func ChangeDeck(roomId, deskConfig, policy string) (domain.Room, []string, error) {
+	var withdrawn []string
+	roomInfo, err := repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		var err error
+		withdrawn, err = roomInfo.ChangeDeck(deskConfig, policy, timer.GetTimeNow())
+		return err
+	})
 	if err != nil {
-		return domain.Room{}, err
+		return domain.Room{}, nil, err
 	}
-	return roomInfo, nil
+	return roomInfo, withdrawn, nil
 }
 
-func SetTicketQueueWithEstimation(queue []domain.TicketEstimation, est *domain.TicketEstimation, roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.SetTicketQueue(queue, now)
-	roomInfo.SetTicketEstimation(est, now)
-	err := repo.SetTicketQueue(roomId, roomInfo)
+// SetPasscode makes the room private behind passcode, or public again when it
+// is empty. Changing it revokes every outstanding invite.
+func SetPasscode(roomId, passcode string) (domain.Room, error) {
+	hash, err := roomaccess.HashPasscode(passcode)
 	if err != nil {
 		return domain.Room{}, err
 	}
-	return roomInfo, nil
+	return repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		roomInfo.SetPasscode(hash, timer.GetTimeNow())
+		return nil
+	})
 }
 
-func SetFinalStoryPoint(roomId string, value string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-	roomInfo.ConfirmFinalStoryPoint(value, now)
-	err := repo.SetFinalStoryPoint(roomId, roomInfo)
+// SetDimensions makes the room vote on dimensions, or with single values
+// again when dimensions is empty, withdrawing every vote of the round. It
+// returns the members whose vote was withdrawn.
+func SetDimensions(roomId string, dimensions []domain.Dimension, formula *domain.ScoreFormula) (domain.Room, []string, error) {
+	var withdrawn []string
+	roomInfo, err := repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		withdrawn = roomInfo.SetDimensions(dimensions, formula, timer.GetTimeNow())
+		return nil
+	})
 	if err != nil {
-		return domain.Room{}, err
+		return domain.Room{}, nil, err
 	}
-	return roomInfo, nil
+	return roomInfo, withdrawn, nil
 }
 
-func ResetRoom(roomId string) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
+// SetVotingMode switches anonymous voting and whether anonymous votes are
+// recorded for the owner.
+func SetVotingMode(roomId string, anonymous, recordVotes bool) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		return roomInfo.SetVotingMode(anonymous, recordVotes, timer.GetTimeNow())
+	})
+}
 
-	roomInfo.Restart(now)
+// SetDelphiMaxRounds caps the rounds each ticket gets, 0 for no cap.
+func SetDelphiMaxRounds(roomId string, maxRounds int) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.SettingsFields, func(roomInfo *domain.Room) error {
+		roomInfo.SetDelphiMaxRounds(maxRounds, timer.GetTimeNow())
+		return nil
+	})
+}
+
+// StartAsync opens an async session on the queued tickets with the given
+// keys, or every unscored one, until deadline.
+func StartAsync(roomId string, keys []string, deadline time.Time) (domain.Room, error) {
+	return repo.UpdateRoom(roomId, repo.AsyncFields, func(roomInfo *domain.Room) error {
+		return roomInfo.StartAsync(keys, deadline, timer.GetTimeNow())
+	})
+}
 
-	err := repo.ResetRoom(roomId, roomInfo)
+// AsyncVote records a vote on a ticket of the async session, and returns
+// the round added to the history if it revealed the ticket.
+func AsyncVote(uid, key, value, roomId string) (domain.Room, []domain.Round, error) {
+	var rounds []domain.Round
+	roomInfo, err := repo.UpdateRoom(roomId, repo.AsyncFields, func(roomInfo *domain.Room) error {
+		index := FindMemberIndex(roomInfo.Members, uid)
+		if index == -1 {
+			return ErrMemberNotFound
+		}
+		var err error
+		rounds, err = roomInfo.AsyncVote(index, key, value, configs.Conf.RoundHistoryLimit, timer.GetTimeNow())
+		return err
+	})
 	if err != nil {
-		return domain.Room{}, err
+		return domain.Room{}, nil, err
 	}
-	return roomInfo, nil
+	return roomInfo, rounds, nil
 }
 
-func ResetRoomWithTicket(roomId string, ticket domain.TicketEstimation, queue []domain.TicketEstimation) (domain.Room, error) {
-	now := timer.GetTimeNow()
-	roomInfo := roomService.GetRoomInfo(roomId)
-
-	roomInfo.RestartWithTicket(ticket, queue, now)
+// CloseAsync reveals what is left of the async session and closes it. With
+// dueOnly it does so only once the deadline has passed, and otherwise
+// returns domain.ErrNoAsyncSession.
+func CloseAsync(roomId string, dueOnly bool) (domain.Room, []domain.Round, error) {
+	var rounds []domain.Round
+	roomInfo, err := repo.UpdateRoom(roomId, repo.AsyncFields, func(roomInfo *domain.Room) error {
+		now := timer.GetTimeNow()
+		if dueOnly && roomInfo.Async != nil && now.Before(roomInfo.Async.Deadline) {
+			return domain.ErrNoAsyncSession
+		}
+		var err error
+		rounds, err = roomInfo.CloseAsync(configs.Conf.RoundHistoryLimit, now)
+		return err
+	})
+	if err != nil {
+		return domain.Room{}, nil, err
+	}
+	return roomInfo, rounds, nil
+}
 
-	err := repo.ResetRoom(roomId, roomInfo)
+// DeleteRoom deletes the room for good and returns it as it was.
+func DeleteRoom(roomId string) (domain.Room, error) {
+	roomInfo, err := roomService.FindRoom(roomId)
 	if err != nil {
 		return domain.Room{}, err
 	}
+	if _, err := repo.DeleteRoom(roomId, false); err != nil {
+		return domain.Room{}, err
+	}
 	return roomInfo, nil
 }
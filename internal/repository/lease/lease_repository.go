@@ -0,0 +1,72 @@
+package lease
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/raksitnongbua/planning-poker-service/internal/repository"
+)
+
+type leaseDocument struct {
+	Holder    string    `firestore:"Holder"`
+	ExpiresAt time.Time `firestore:"ExpiresAt"`
+}
+
+// TryAcquire takes or renews the named lease for holder. It returns false when
+// another holder owns an unexpired lease, so only one replica runs the job.
+func TryAcquire(name, holder string, ttl time.Duration) (bool, error) {
+	docRef := repository.LeasesColRef.Doc(name)
+	acquired := false
+
+	err := repository.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+		now := time.Now()
+
+		snap, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var current leaseDocument
+			if err := snap.DataTo(&current); err != nil {
+				return err
+			}
+			if current.Holder != holder && current.ExpiresAt.After(now) {
+				return nil
+			}
+		}
+
+		acquired = true
+		return tx.Set(docRef, leaseDocument{Holder: holder, ExpiresAt: now.Add(ttl)})
+	})
+	if err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+// Release gives up the lease early if holder still owns it.
+func Release(name, holder string) error {
+	docRef := repository.LeasesColRef.Doc(name)
+	return repository.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		snap, err := tx.Get(docRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+		var current leaseDocument
+		if err := snap.DataTo(&current); err != nil {
+			return err
+		}
+		if current.Holder != holder {
+			return nil
+		}
+		return tx.Delete(docRef)
+	})
+}
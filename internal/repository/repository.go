@@ -2,39 +2,99 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
 
 	"cloud.google.com/go/firestore"
 	firebase "firebase.google.com/go"
 	"github.com/raksitnongbua/planning-poker-service/configs"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 var (
-	clientFirestore *firestore.Client
-	RoomsColRef     *firestore.CollectionRef
+	clientFirestore     *firestore.Client
+	RoomsColRef         *firestore.CollectionRef
+	ArchivedRoomsColRef *firestore.CollectionRef
+	LeasesColRef        *firestore.CollectionRef
 )
 
 func newRoomsCollectionRef() *firestore.CollectionRef {
 	return clientFirestore.Collection("rooms")
 }
 
+func newArchivedRoomsCollectionRef() *firestore.CollectionRef {
+	return clientFirestore.Collection("archived_rooms")
+}
+
+func newLeasesCollectionRef() *firestore.CollectionRef {
+	return clientFirestore.Collection("leases")
+}
+
 func Init() {
 	firebaseCredentials := configs.Conf.FirebaseCredentials
 	if firebaseCredentials == "" {
 		log.Fatal("FIREBASE_CREDENTIALS is not set")
 	}
-	opt := option.WithCredentialsJSON([]byte(firebaseCredentials))
+	if err := InitWithCredentials(firebaseCredentials); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// InitWithCredentials connects to Firestore using a service account JSON.
+func InitWithCredentials(credentialsJSON string) error {
+	opt := option.WithCredentialsJSON([]byte(credentialsJSON))
 
 	client, err := firebase.NewApp(context.Background(), nil, opt)
 	if err != nil {
-		log.Fatalf("error initializing app: %v", err)
+		return fmt.Errorf("error initializing app: %w", err)
 	}
 
 	firestore, err := client.Firestore(context.Background())
 	if err != nil {
-		log.Fatalf("error initializing firestore: %v", err)
+		return fmt.Errorf("error initializing firestore: %w", err)
+	}
+	setClient(firestore)
+	return nil
+}
+
+// InitEmulator connects to the Firestore emulator named by the
+// FIRESTORE_EMULATOR_HOST environment variable; no credentials are needed.
+func InitEmulator(projectID string) error {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		return errors.New("FIRESTORE_EMULATOR_HOST is not set")
+	}
+	client, err := firestore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return fmt.Errorf("error initializing firestore emulator client: %w", err)
 	}
-	clientFirestore = firestore
+	setClient(client)
+	return nil
+}
+
+func setClient(client *firestore.Client) {
+	clientFirestore = client
 	RoomsColRef = newRoomsCollectionRef()
+	ArchivedRoomsColRef = newArchivedRoomsCollectionRef()
+	LeasesColRef = newLeasesCollectionRef()
+}
+
+// RunTransaction runs f in a Firestore transaction, retrying on contention.
+func RunTransaction(ctx context.Context, f func(context.Context, *firestore.Transaction) error) error {
+	return clientFirestore.RunTransaction(ctx, f)
+}
+
+// Ping performs a cheap read against Firestore to confirm the client is
+// configured and the backend is reachable. An empty collection is healthy.
+func Ping(ctx context.Context) error {
+	if clientFirestore == nil {
+		return errors.New("firestore client is not initialized")
+	}
+	_, err := RoomsColRef.Limit(1).Documents(ctx).Next()
+	if err != nil && err != iterator.Done {
+		return err
+	}
+	return nil
 }
@@ -2,20 +2,21 @@ package room
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"time"
 
 	"cloud.google.com/go/firestore"
-	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/raksitnongbua/planning-poker-service/internal/core/domain"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/common"
 	"github.com/raksitnongbua/planning-poker-service/internal/repository"
 	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
 )
 
-const roomRetention = 30 * 24 * time.Hour
-
 func QueryRecentRooms(id string) (recentRooms []map[string]interface{}, err error) {
 	query := repository.RoomsColRef.Where("EverJoinedMemberIDs", "array-contains", id).OrderBy("UpdatedAt", firestore.Desc)
 
@@ -47,10 +48,117 @@ func CreateNewRoom(roomId string, room *domain.Room) error {
 	return err
 }
 
-func RoomExists(roomId string) bool {
+// SaveRoom writes the whole room document, replacing any existing one.
+func SaveRoom(roomId string, room domain.Room) error {
+	logger.Info("firestore save room", "roomId", roomId)
+	_, err := repository.RoomsColRef.Doc(roomId).Set(context.Background(), room)
+	return err
+}
+
+// ErrRoomNotFound is returned for a room that does not exist, or no longer
+// does.
+var ErrRoomNotFound = errors.New("room not found")
+
+// FindRoom is like GetRoomInfo but reports a missing room instead of exiting.
+func FindRoom(roomId string) (domain.Room, bool, error) {
+	docSnapshot, err := repository.RoomsColRef.Doc(roomId).Get(context.Background())
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.Room{}, false, nil
+		}
+		return domain.Room{}, false, err
+	}
+	var roomInfo domain.Room
+	if err := docSnapshot.DataTo(&roomInfo); err != nil {
+		return domain.Room{}, false, err
+	}
+	roomInfo.FillRoundNotes()
+	return roomInfo, true, nil
+}
+
+// ListRooms returns rooms ordered by most recently updated, restricted to
+// UpdatedAt in [updatedAfter, updatedBefore) when those bounds are non-zero.
+// keep filters in memory; iteration stops once limit rooms are collected.
+func ListRooms(updatedAfter, updatedBefore time.Time, keep func(domain.Room) bool, limit int) ([]domain.RoomRecord, error) {
+	query := repository.RoomsColRef.OrderBy("UpdatedAt", firestore.Desc)
+	if !updatedAfter.IsZero() {
+		query = query.Where("UpdatedAt", ">=", updatedAfter)
+	}
+	if !updatedBefore.IsZero() {
+		query = query.Where("UpdatedAt", "<", updatedBefore)
+	}
+
+	iter := query.Documents(context.Background())
+	defer iter.Stop()
+
+	records := []domain.RoomRecord{}
+	for len(records) < limit {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var room domain.Room
+		if err := doc.DataTo(&room); err != nil {
+			return nil, err
+		}
+		if keep != nil && !keep(room) {
+			continue
+		}
+		records = append(records, domain.RoomRecord{ID: doc.Ref.ID, Room: room})
+	}
+	return records, nil
+}
+
+func QueryRoomsEverJoinedBy(memberID string) ([]domain.RoomRecord, error) {
+	docs, err := repository.RoomsColRef.Where("EverJoinedMemberIDs", "array-contains", memberID).
+		OrderBy("UpdatedAt", firestore.Desc).Documents(context.Background()).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]domain.RoomRecord, 0, len(docs))
+	for _, doc := range docs {
+		var room domain.Room
+		if err := doc.DataTo(&room); err != nil {
+			return nil, err
+		}
+		records = append(records, domain.RoomRecord{ID: doc.Ref.ID, Room: room})
+	}
+	return records, nil
+}
+
+// DeleteRoom removes a room, first copying it to archived_rooms in the same
+// transaction when archive is set. Returns false if the room does not exist.
+func DeleteRoom(roomId string, archive bool) (bool, error) {
+	logger.Info("firestore delete room", "roomId", roomId, "archive", archive)
 	docRef := repository.RoomsColRef.Doc(roomId)
-	_, err := docRef.Get(context.Background())
-	return err == nil
+	found := false
+	err := repository.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		found = false
+		snap, err := tx.Get(docRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			return err
+		}
+		found = true
+		if archive {
+			var room domain.Room
+			if err := snap.DataTo(&room); err != nil {
+				return err
+			}
+			archived := domain.NewArchivedRoom(roomId, room, time.Now())
+			if err := tx.Set(repository.ArchivedRoomsColRef.Doc(roomId), archived); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(docRef)
+	})
+	return found, err
 }
 
 func GetRoomInfo(roomId string) domain.Room {
@@ -63,207 +171,323 @@ func GetRoomInfo(roomId string) domain.Room {
 	if err := docSnapshot.DataTo(&roomInfo); err != nil {
 		log.Fatalf("Failed to map Firestore document data: %v", err)
 	}
+	roomInfo.FillRoundNotes()
 	return roomInfo
 }
 
-func UpdateEstimatedValue(roomId string, roomInfo domain.Room) error {
+// Fields lists what a change writes to the room document, besides the
+// version UpdateRoom bumps.
+type Fields func(roomInfo domain.Room) []firestore.Update
+
+// UpdateRoom loads the room, applies change and writes fields with the
+// version bumped, all in one transaction. Firestore retries the transaction
+// when another write lands on the room first, so concurrent changes apply one
+// after the other and each gets a version of its own; change must therefore
+// be safe to run more than once. Nothing is written when change fails.
+func UpdateRoom(roomId string, fields Fields, change func(roomInfo *domain.Room) error) (domain.Room, error) {
+	logger.Info("firestore update room", "roomId", roomId)
+	return updateRoom(roomId, true, fields, change)
+}
+
+func updateRoom(roomId string, bump bool, fields Fields, change func(roomInfo *domain.Room) error) (domain.Room, error) {
 	docRef := repository.RoomsColRef.Doc(roomId)
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+	var roomInfo domain.Room
+	err := repository.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		roomInfo = domain.Room{}
+		snap, err := tx.Get(docRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return ErrRoomNotFound
+			}
+			return err
+		}
+		if err := snap.DataTo(&roomInfo); err != nil {
+			return err
+		}
+		roomInfo.FillRoundNotes()
+		if err := change(&roomInfo); err != nil {
+			return err
+		}
+		updates := fields(roomInfo)
+		if bump {
+			roomInfo.BumpVersion()
+			updates = append(updates, firestore.Update{Path: "Version", Value: roomInfo.Version})
+		}
+		return tx.Update(docRef, updates)
+	})
+	if err != nil {
+		return domain.Room{}, err
+	}
+	return roomInfo, nil
+}
+
+// ticketValue is the active ticket to write, or a delete when there is none.
+func ticketValue(roomInfo domain.Room) interface{} {
+	if roomInfo.TicketEstimation != nil {
+		return roomInfo.TicketEstimation
+	}
+	return firestore.Delete
+}
+
+// queueValue is the ticket queue to write, or a delete when it is empty.
+func queueValue(roomInfo domain.Room) interface{} {
+	if len(roomInfo.TicketQueue) > 0 {
+		return roomInfo.TicketQueue
+	}
+	return firestore.Delete
+}
+
+func EstimatedValueFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "Members", Value: roomInfo.Members},
 		{Path: "Result", Value: roomInfo.Result},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
-	})
-	return err
+	}
 }
 
-func UpdateNewJoiner(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore update new joiner", "roomId", roomId)
-	docRef := repository.RoomsColRef.Doc(roomId)
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+func NewJoinerFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "Members", Value: roomInfo.Members},
 		{Path: "MemberIDs", Value: roomInfo.MemberIDs},
 		{Path: "EverJoinedMemberIDs", Value: roomInfo.EverJoinedMemberIDs},
-		{Path: "UpdatedAt", Value: time.Now()},
-	})
-	return err
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
+	}
 }
 
-func KickMember(roomId string, roomInfo domain.Room) error {
-	docRef := repository.RoomsColRef.Doc(roomId)
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+func KickMemberFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "Members", Value: roomInfo.Members},
 		{Path: "MemberIDs", Value: roomInfo.MemberIDs},
+		{Path: "Result", Value: roomInfo.Result},
+		{Path: "Bans", Value: roomInfo.Bans},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
-	})
-	return err
+	}
 }
 
-func SetRevealCards(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore reveal cards", "roomId", roomId)
-	docRef := repository.RoomsColRef.Doc(roomId)
+// UpdateBans is UpdateRoom for a change to the ban list alone. Bans are not
+// part of the room clients see, so the version is left alone.
+func UpdateBans(roomId string, change func(roomInfo *domain.Room) error) error {
+	logger.Info("firestore update bans", "roomId", roomId)
+	_, err := updateRoom(roomId, false, func(roomInfo domain.Room) []firestore.Update {
+		return []firestore.Update{{Path: "Bans", Value: roomInfo.Bans}}
+	}, change)
+	return err
+}
 
-	var ticketValue interface{}
-	if roomInfo.TicketEstimation != nil {
-		ticketValue = roomInfo.TicketEstimation
-	} else {
-		ticketValue = firestore.Delete
+// LeaveRoomFields writes the members after one left, with the result their
+// vote no longer counts towards.
+func LeaveRoomFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "Members", Value: roomInfo.Members},
+		{Path: "MemberIDs", Value: roomInfo.MemberIDs},
+		{Path: "Result", Value: roomInfo.Result},
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 	}
-	var queueValue interface{}
-	if len(roomInfo.TicketQueue) > 0 {
-		queueValue = roomInfo.TicketQueue
-	} else {
-		queueValue = firestore.Delete
+}
+
+func MemberProfileFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "Members", Value: roomInfo.Members},
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 	}
+}
 
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+func RevealCardsFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "Members", Value: roomInfo.Members},
 		{Path: "Status", Value: roomInfo.Status},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 		{Path: "FinalStoryPoint", Value: roomInfo.FinalStoryPoint},
-		{Path: "TicketEstimation", Value: ticketValue},
-		{Path: "TicketQueue", Value: queueValue},
-	})
-	return err
-}
-
-func ResetRoom(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore reset room", "roomId", roomId)
-	docRef := repository.RoomsColRef.Doc(roomId)
-
-	var ticketValue interface{}
-	if roomInfo.TicketEstimation != nil {
-		ticketValue = roomInfo.TicketEstimation
-	} else {
-		ticketValue = firestore.Delete
-	}
-	var queueValue interface{}
-	if len(roomInfo.TicketQueue) > 0 {
-		queueValue = roomInfo.TicketQueue
-	} else {
-		queueValue = firestore.Delete
+		{Path: "TicketEstimation", Value: ticketValue(roomInfo)},
+		{Path: "TicketQueue", Value: queueValue(roomInfo)},
+		{Path: "Rounds", Value: roomInfo.Rounds},
+		{Path: "DimensionStats", Value: roomInfo.DimensionStats},
+		{Path: "ConfidenceStats", Value: roomInfo.ConfidenceStats},
+		{Path: "DelphiRounds", Value: roomInfo.DelphiRounds},
+		{Path: "Convergence", Value: roomInfo.Convergence},
+		{Path: "AwaitingFacilitator", Value: roomInfo.AwaitingFacilitator},
 	}
+}
 
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+func ResetRoomFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "Status", Value: roomInfo.Status},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 		{Path: "Members", Value: roomInfo.Members},
 		{Path: "Result", Value: roomInfo.Result},
-		{Path: "TicketEstimation", Value: ticketValue},
-		{Path: "TicketQueue", Value: queueValue},
+		{Path: "TicketEstimation", Value: ticketValue(roomInfo)},
+		{Path: "TicketQueue", Value: queueValue(roomInfo)},
 		{Path: "FinalStoryPoint", Value: ""},
-	})
-	return err
-}
-
-func SetFinalStoryPoint(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore set final story point", "roomId", roomId, "value", roomInfo.FinalStoryPoint)
-	docRef := repository.RoomsColRef.Doc(roomId)
-
-	var ticketValue interface{}
-	if roomInfo.TicketEstimation != nil {
-		ticketValue = roomInfo.TicketEstimation
-	} else {
-		ticketValue = firestore.Delete
-	}
-	var queueValue interface{}
-	if len(roomInfo.TicketQueue) > 0 {
-		queueValue = roomInfo.TicketQueue
-	} else {
-		queueValue = firestore.Delete
+		{Path: "DimensionStats", Value: firestore.Delete},
+		{Path: "ConfidenceStats", Value: firestore.Delete},
+		{Path: "DelphiRounds", Value: roomInfo.DelphiRounds},
+		{Path: "Convergence", Value: roomInfo.Convergence},
+		{Path: "AwaitingFacilitator", Value: roomInfo.AwaitingFacilitator},
 	}
+}
 
-	_, err := docRef.Update(context.Background(), []firestore.Update{
+func FinalStoryPointFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
 		{Path: "FinalStoryPoint", Value: roomInfo.FinalStoryPoint},
+		{Path: "AwaitingFacilitator", Value: roomInfo.AwaitingFacilitator},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
-		{Path: "TicketEstimation", Value: ticketValue},
-		{Path: "TicketQueue", Value: queueValue},
-	})
-	return err
+		{Path: "TicketEstimation", Value: ticketValue(roomInfo)},
+		{Path: "TicketQueue", Value: queueValue(roomInfo)},
+	}
 }
 
-func UpdateLastActive(roomId string, members []domain.Member, updatedAt time.Time) error {
-	docRef := repository.RoomsColRef.Doc(roomId)
-	_, err := docRef.Update(context.Background(), []firestore.Update{
-		{Path: "Members", Value: members},
-		{Path: "UpdatedAt", Value: updatedAt},
-	})
+// UpdateLastActive is UpdateRoom for a change to when members were last
+// active. Presence is broadcast from memory, so the version is left alone.
+func UpdateLastActive(roomId string, change func(roomInfo *domain.Room) error) error {
+	_, err := updateRoom(roomId, false, func(roomInfo domain.Room) []firestore.Update {
+		return []firestore.Update{
+			{Path: "Members", Value: roomInfo.Members},
+			{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
+		}
+	}, change)
 	return err
 }
 
-func SetTicketEstimation(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore set ticket estimation", "roomId", roomId)
-	docRef := repository.RoomsColRef.Doc(roomId)
-	var ticketValue interface{}
-	if roomInfo.TicketEstimation != nil {
-		ticketValue = roomInfo.TicketEstimation
-	} else {
-		ticketValue = firestore.Delete
+// SettingsFields writes the owner-managed settings together with the votes,
+// which a deck change may withdraw.
+func SettingsFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "Name", Value: roomInfo.Name},
+		{Path: "DeskConfig", Value: roomInfo.DeskConfig},
+		{Path: "Private", Value: roomInfo.Private},
+		{Path: "PasscodeHash", Value: roomInfo.PasscodeHash},
+		{Path: "AnonymousVoting", Value: roomInfo.AnonymousVoting},
+		{Path: "RecordVotes", Value: roomInfo.RecordVotes},
+		{Path: "Dimensions", Value: roomInfo.Dimensions},
+		{Path: "ScoreFormula", Value: roomInfo.ScoreFormula},
+		{Path: "DimensionStats", Value: roomInfo.DimensionStats},
+		{Path: "DelphiMaxRounds", Value: roomInfo.DelphiMaxRounds},
+		{Path: "Members", Value: roomInfo.Members},
+		{Path: "Result", Value: roomInfo.Result},
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 	}
-	_, err := docRef.Update(context.Background(), []firestore.Update{
-		{Path: "TicketEstimation", Value: ticketValue},
+}
+
+func TicketEstimationFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "TicketEstimation", Value: ticketValue(roomInfo)},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
-	})
-	return err
+	}
 }
 
-func SetTicketQueue(roomId string, roomInfo domain.Room) error {
-	logger.Info("firestore set ticket queue", "roomId", roomId)
-	docRef := repository.RoomsColRef.Doc(roomId)
-	var queueValue interface{}
-	if len(roomInfo.TicketQueue) > 0 {
-		queueValue = roomInfo.TicketQueue
-	} else {
-		queueValue = firestore.Delete
+func TicketQueueFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "TicketQueue", Value: queueValue(roomInfo)},
+		{Path: "TicketEstimation", Value: ticketValue(roomInfo)},
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
 	}
-	var ticketValue interface{}
-	if roomInfo.TicketEstimation != nil {
-		ticketValue = roomInfo.TicketEstimation
+}
+
+// NoteFields writes the room's notes. Rounds take theirs from them when the
+// room is loaded, so the history is left alone.
+func NoteFields(roomInfo domain.Room) []firestore.Update {
+	return []firestore.Update{
+		{Path: "Notes", Value: roomInfo.Notes},
+		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
+	}
+}
+
+// AsyncFields writes the room's async session, with the scores and history
+// its reveals stamped. It leaves Members alone.
+func AsyncFields(roomInfo domain.Room) []firestore.Update {
+	var asyncValue interface{}
+	if roomInfo.Async != nil {
+		asyncValue = roomInfo.Async
 	} else {
-		ticketValue = firestore.Delete
+		asyncValue = firestore.Delete
 	}
-	_, err := docRef.Update(context.Background(), []firestore.Update{
-		{Path: "TicketQueue", Value: queueValue},
-		{Path: "TicketEstimation", Value: ticketValue},
+	return []firestore.Update{
+		{Path: "Async", Value: asyncValue},
+		{Path: "TicketQueue", Value: roomInfo.TicketQueue},
+		{Path: "Rounds", Value: roomInfo.Rounds},
 		{Path: "UpdatedAt", Value: roomInfo.UpdatedAt},
-	})
-	return err
+	}
 }
 
-func DeleteExpiredRooms() (domain.CleanupResult, error) {
-	ctx := context.Background()
-	threshold := time.Now().Add(-roomRetention)
+// QueryAsyncDue returns the IDs of rooms whose async session is past its
+// deadline.
+func QueryAsyncDue(now time.Time) ([]string, error) {
+	docs, err := repository.RoomsColRef.Where("Async.Deadline", "<=", now).Documents(context.Background()).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.Ref.ID)
+	}
+	return ids, nil
+}
 
-	docs, err := repository.RoomsColRef.Where("UpdatedAt", "<", threshold).Documents(ctx).GetAll()
+func QueryExpiredRooms(threshold time.Time) ([]domain.RoomRecord, error) {
+	docs, err := repository.RoomsColRef.Where("UpdatedAt", "<", threshold).Documents(context.Background()).GetAll()
 	if err != nil {
-		return domain.CleanupResult{}, err
+		return nil, err
 	}
 
-	var deletedRooms []domain.DeletedRoom
+	records := make([]domain.RoomRecord, 0, len(docs))
 	for _, doc := range docs {
 		var room domain.Room
 		if err := doc.DataTo(&room); err != nil {
-			return domain.CleanupResult{}, err
+			return nil, err
 		}
-		if _, err := doc.Ref.Delete(ctx); err != nil {
-			return domain.CleanupResult{}, err
-		}
-		deletedRooms = append(deletedRooms, domain.DeletedRoom{
-			ID:        doc.Ref.ID,
-			Name:      room.Name,
-			UpdatedAt: room.UpdatedAt,
-		})
+		records = append(records, domain.RoomRecord{ID: doc.Ref.ID, Room: room})
 	}
+	return records, nil
+}
+
+// DeleteExpiredRooms deletes the given rooms in a single transaction,
+// re-checking each one is still idle past threshold so a room that became
+// active since it was queried is left alone. When archive is set, each room
+// is copied to the archived_rooms collection in the same transaction.
+// Returns the IDs that were actually deleted.
+func DeleteExpiredRooms(ids []string, threshold time.Time, archive bool) ([]string, error) {
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, repository.RoomsColRef.Doc(id))
+	}
+
+	var deleted []string
+	err := repository.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		deleted = deleted[:0]
+		snaps, err := tx.GetAll(refs)
+		if err != nil {
+			return err
+		}
 
-	count := len(deletedRooms)
-	message := fmt.Sprintf("Successfully deleted %d expired room(s) inactive for more than 30 days", count)
-	if count == 0 {
-		message = "No expired rooms found"
+		now := time.Now()
+		for _, snap := range snaps {
+			if !snap.Exists() {
+				continue
+			}
+			var room domain.Room
+			if err := snap.DataTo(&room); err != nil {
+				return err
+			}
+			if !room.UpdatedAt.Before(threshold) {
+				continue
+			}
+			if archive {
+				archived := domain.NewArchivedRoom(snap.Ref.ID, room, now)
+				if err := tx.Set(repository.ArchivedRoomsColRef.Doc(snap.Ref.ID), archived); err != nil {
+					return err
+				}
+			}
+			if err := tx.Delete(snap.Ref); err != nil {
+				return err
+			}
+			deleted = append(deleted, snap.Ref.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return domain.CleanupResult{
-		Message:   message,
-		Deleted:   count,
-		Rooms:     deletedRooms,
-		CleanedAt: time.Now(),
-	}, nil
+	logger.Info("firestore delete expired rooms", "requested", len(ids), "deleted", len(deleted), "archived", archive)
+	return deleted, nil
 }
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/raksitnongbua/planning-poker-service/configs"
+	asyncestimation "github.com/raksitnongbua/planning-poker-service/internal/core/usecase/async_estimation"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/usecase/cleanup"
 	"github.com/raksitnongbua/planning-poker-service/internal/repository"
 	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
 	"github.com/raksitnongbua/planning-poker-service/protocol"
@@ -9,7 +14,18 @@ import (
 
 func main() {
 	configs.Init()
+	configs.Conf.Print(os.Stdout)
 	logger.Init(configs.Conf.AppEnv)
 	repository.Init()
-	protocol.ServeREST()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cleanup.StartScheduler(ctx)
+	go asyncestimation.StartScheduler(ctx)
+
+	if err := protocol.ServeREST(); err != nil {
+		logger.Error("server failed", "error", err)
+		cancel()
+		os.Exit(1)
+	}
 }
@@ -1,7 +1,12 @@
 package protocol
 
 import (
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
@@ -10,33 +15,39 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 
 	"github.com/raksitnongbua/planning-poker-service/configs"
+	adminauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/admin"
+	participantauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/participant"
 	websocketauth "github.com/raksitnongbua/planning-poker-service/internal/core/auth/websocket"
+	"github.com/raksitnongbua/planning-poker-service/internal/core/handler/admin"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/handler/health"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/handler/room"
+	roomevents "github.com/raksitnongbua/planning-poker-service/internal/core/handler/room_events"
 	roomsocket "github.com/raksitnongbua/planning-poker-service/internal/core/handler/room_socket"
 	"github.com/raksitnongbua/planning-poker-service/internal/core/handler/user"
 	"github.com/raksitnongbua/planning-poker-service/pkg/logger"
 )
 
-func ServeREST() {
+// ServeREST serves until SIGINT/SIGTERM, or returns the error that kept it
+// from listening.
+func ServeREST() error {
 	app := fiber.New(fiber.Config{
-		BodyLimit: 1 * 1024 * 1024, // 1MB max request body (security: prevent memory exhaustion)
+		BodyLimit: configs.Conf.BodyLimit, // max request body (security: prevent memory exhaustion)
 	})
 
 	// CORS configuration with explicit allowed origins (security: prevent CSRF)
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     configs.Conf.AllowedOrigins,
-		AllowMethods:     "GET,POST,DELETE",
-		AllowHeaders:     "Content-Type,Cookie",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE",
+		AllowHeaders:     "Content-Type,Cookie,Authorization,Last-Event-ID",
 		AllowCredentials: true,
-		MaxAge:           86400, // 24 hours
+		MaxAge:           int(configs.Conf.CORSMaxAge.Seconds()),
 	}))
 
 	// Rate limiting for HTTP endpoints (security: prevent resource exhaustion)
-	// 200 requests per minute per IP
+	// RATE_LIMIT_MAX requests per RATE_LIMIT_WINDOW per IP
 	app.Use(limiter.New(limiter.Config{
-		Max:        200,
-		Expiration: 1 * time.Minute,
+		Max:        configs.Conf.RateLimitMax,
+		Expiration: configs.Conf.RateLimitWindow,
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return c.IP() // Rate limit by client IP
 		},
@@ -52,11 +63,14 @@ func ServeREST() {
 	}))
 
 	app.Get("/health", health.HealthCheckHandler)
+	app.Get("/livez", health.LivenessHandler)
+	app.Get("/readyz", health.ReadinessHandler)
 	app.Static("/openapi.yaml", "./openapi.yaml")
+	app.Static("/asyncapi.yaml", "./asyncapi.yaml")
 	app.Get("/docs", docsHandler)
 
-	// WebSocket authentication middleware - Phase 1: log-only mode (security: monitoring cookie auth)
-	// Phase 2 (rejection) will be enabled after 48h metrics confirm cookie transmission works
+	// WebSocket authentication middleware. The uid in the URL is never
+	// trusted; a connection without a valid cookie is an anonymous viewer.
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if !websocket.IsWebSocketUpgrade(c) {
 			return fiber.ErrUpgradeRequired
@@ -65,8 +79,8 @@ func ServeREST() {
 		// Try to authenticate from cookies (NextAuth session or guest UID)
 		authenticatedUID, err := websocketauth.ExtractAuthenticatedUID(c)
 		if err != nil {
-			// Phase 1: Log but allow connection (fallback to URL param in handler)
-			logger.Warn("websocket auth from cookie failed - falling back to URL param",
+			// Allow the connection; the handler treats it as anonymous
+			logger.Warn("websocket auth from cookie failed - connecting anonymously",
 				"error", err, "path", c.Path(), "remote_addr", c.IP())
 		} else {
 			// Success: cookie auth worked
@@ -80,11 +94,20 @@ func ServeREST() {
 
 	// WebSocket configuration with security limits
 	app.Get("/ws/room/:uid/:id", websocket.New(roomsocket.SocketRoomHandler, websocket.Config{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:  configs.Conf.WSReadBufferSize,
+		WriteBufferSize: configs.Conf.WSWriteBufferSize,
 	}))
 
 	api := app.Group("/api")
+
+	adminGroup := api.Group("/admin", adminauth.RequireAdmin)
+	adminGroup.Post("/rooms/cleanup", room.CleanupExpiredRoomsHandler)
+	adminGroup.Get("/rooms", admin.ListRoomsHandler)
+	adminGroup.Get("/rooms/:roomId", admin.GetRoomHandler)
+	adminGroup.Post("/rooms/:roomId/close-sockets", admin.CloseRoomSocketsHandler)
+	adminGroup.Delete("/rooms/:roomId", admin.DeleteRoomHandler)
+	adminGroup.Get("/users/:userId/rooms", admin.UserRoomsHandler)
+
 	v1 := api.Group("v1")
 	v1.Get("/", func(c *fiber.Ctx) error {
 		return c.Status(http.StatusOK).SendString("Api v1 is ready!")
@@ -92,9 +115,71 @@ func ServeREST() {
 	v1.Get("/guest/sign-in", user.SignInWithGuestHandler)
 	v1.Post("/new-room", room.CreateNewRoomHandler)
 	v1.Get("/room/recent-rooms/:id", room.GetRecentRoomsHandler)
-	v1.Delete("/rooms/expired", room.CleanupExpiredRoomsHandler)
-	v1.Delete("/rooms/:roomId/members/:memberId", room.KickMemberHandler)
+	v1.Delete("/rooms/expired", adminauth.RequireAdmin, room.CleanupExpiredRoomsHandler)
+	v1.Delete("/rooms/:roomId/members/:memberId", participantauth.RequireParticipant, room.KickMemberHandler)
+
+	// Room resource. Writes run the socket actions, so connected sockets and
+	// event streams see every change; the event stream serves clients
+	// without WebSockets.
+	v1.Get("/rooms/:roomId", participantauth.Identify, room.GetRoomHandler)
+	v1.Patch("/rooms/:roomId", participantauth.RequireParticipant, roomsocket.SettingsHandler)
+	v1.Delete("/rooms/:roomId", participantauth.RequireParticipant, roomsocket.ActionHandler("DELETE_ROOM"))
+	v1.Post("/rooms/:roomId/invites", participantauth.RequireParticipant, room.CreateInviteHandler)
+	v1.Get("/rooms/:roomId/bans", participantauth.RequireParticipant, room.ListBansHandler)
+	v1.Get("/rooms/:roomId/history", participantauth.RequireParticipant, room.RoundHistoryHandler)
+	v1.Delete("/rooms/:roomId/bans/:userId", participantauth.RequireParticipant, room.LiftBanHandler)
+	v1.Get("/rooms/:roomId/events", participantauth.Identify, roomevents.StreamHandler)
+	v1.Post("/rooms/:roomId/join", participantauth.RequireParticipant, roomsocket.ActionHandler("JOIN_ROOM"))
+	v1.Post("/rooms/:roomId/leave", participantauth.RequireParticipant, roomsocket.ActionHandler("LEAVE_ROOM"))
+	v1.Put("/rooms/:roomId/profile", participantauth.RequireParticipant, roomsocket.ActionHandler("UPDATE_PROFILE"))
+	v1.Post("/rooms/:roomId/votes", participantauth.RequireParticipant, roomsocket.ActionHandler("UPDATE_ESTIMATED_VALUE"))
+	v1.Post("/rooms/:roomId/reveal", participantauth.RequireParticipant, roomsocket.ActionHandler("REVEAL_CARDS"))
+	v1.Post("/rooms/:roomId/rounds", participantauth.RequireParticipant, roomsocket.ActionHandler("NEXT_ROUND"))
+	v1.Post("/rooms/:roomId/revote", participantauth.RequireParticipant, roomsocket.ActionHandler("REVOTE"))
+	v1.Put("/rooms/:roomId/queue", participantauth.RequireParticipant, roomsocket.ActionHandler("SET_TICKET_QUEUE"))
+	v1.Patch("/rooms/:roomId/queue", participantauth.RequireParticipant, roomsocket.ActionHandler("SET_TICKET_QUEUE_WITH_ESTIMATION"))
+	v1.Post("/rooms/:roomId/notes", participantauth.RequireParticipant, roomsocket.ActionHandler("ADD_NOTE"))
+	v1.Put("/rooms/:roomId/ticket-details", participantauth.RequireParticipant, roomsocket.ActionHandler("SET_TICKET_DETAILS"))
+	v1.Put("/rooms/:roomId/final-score", participantauth.RequireParticipant, roomsocket.ActionHandler("SET_FINAL_STORY_POINT"))
+	v1.Post("/rooms/:roomId/async", participantauth.RequireParticipant, roomsocket.ActionHandler("START_ASYNC"))
+	v1.Post("/rooms/:roomId/async/votes", participantauth.RequireParticipant, roomsocket.ActionHandler("ASYNC_VOTE"))
+	v1.Delete("/rooms/:roomId/async", participantauth.RequireParticipant, roomsocket.ActionHandler("END_ASYNC"))
+
+	addr := ":" + strconv.Itoa(configs.Conf.Port)
+	listenErr := make(chan error, 1)
+	go func() {
+		logger.Info("server starting", "port", configs.Conf.Port, "env", configs.Conf.AppEnv)
+		listenErr <- app.Listen(addr)
+	}()
+
+	return waitForShutdown(app, listenErr)
+}
+
+// waitForShutdown blocks until SIGINT/SIGTERM, then fails readiness and waits
+// for load balancers to notice before draining in-flight requests. If the
+// server stops listening first, its error is returned.
+func waitForShutdown(app *fiber.App, listenErr <-chan error) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var sig os.Signal
+	select {
+	case err := <-listenErr:
+		if err == nil {
+			err = errors.New("server stopped listening")
+		}
+		return err
+	case sig = <-quit:
+	}
+
+	logger.Info("shutdown signal received", "signal", sig.String())
+	health.MarkShuttingDown()
+	time.Sleep(configs.Conf.ShutdownDrainDelay)
 
-	logger.Info("server starting", "port", "8080", "env", configs.Conf.AppEnv)
-	app.Listen(":8080")
+	if err := app.ShutdownWithTimeout(configs.Conf.ShutdownTimeout); err != nil {
+		logger.Error("graceful shutdown failed", "error", err)
+	}
+	logger.Info("server stopped")
+	return nil
 }